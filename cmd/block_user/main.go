@@ -5,6 +5,7 @@ import (
 	"flag"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/lovoo/goka"
 	"github.com/niksmo/messaging/internal/processor/blocker"
@@ -21,9 +22,9 @@ func main() {
 	config := loadConfig()
 	logger := logger.New(config.logLevel)
 
-	name, blocked := getFlags()
+	f := getFlags()
 
-	if err := validateName(name, logger); err != nil {
+	if err := validateFlags(f, logger); err != nil {
 		logger.Error().Err(err).Send()
 		flag.CommandLine.Usage()
 		os.Exit(1)
@@ -31,11 +32,30 @@ func main() {
 
 	emitter := createEmitter(logger, config.brokers, config.topic)
 
-	err := emitter.EmitSync(name, blocker.BlockValue(blocked))
+	now := time.Now().UTC()
+	e := blocker.Event{
+		Action:    blocker.ActionUnblock,
+		Actor:     f.actor,
+		Reason:    f.reason,
+		Timestamp: now,
+	}
+	switch {
+	case f.shadow:
+		e.Action = blocker.ActionShadowBan
+	case f.blocked:
+		e.Action = blocker.ActionBlock
+	}
+	if f.duration != 0 {
+		e.ExpiresAt = now.Add(f.duration)
+	}
+
+	err := emitter.EmitSync(f.name, e)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("failed to emit block value")
+		logger.Fatal().Err(err).Msg("failed to emit moderation event")
 	}
-	logger.Info().Str("name", name).Bool("blocked", blocked).Send()
+	logger.Info().Str("name", f.name).Str("action", e.Action).Str(
+		"actor", e.Actor).Time("expiresAt", e.ExpiresAt).Str(
+		"reason", e.Reason).Send()
 }
 
 func loadConfig() config {
@@ -50,24 +70,54 @@ func loadConfig() config {
 	}
 }
 
-func getFlags() (name string, blocked bool) {
-	flag.BoolVar(&blocked, "blocked", false, "block value")
-	flag.StringVar(&name, "name", "", "user name")
+type flags struct {
+	name     string
+	blocked  bool
+	shadow   bool
+	duration time.Duration
+	reason   string
+	actor    string
+}
+
+func getFlags() (f flags) {
+	flag.BoolVar(&f.blocked, "blocked", false, "block value")
+	flag.StringVar(&f.name, "name", "", "user name")
+	flag.BoolVar(&f.shadow, "shadow", false,
+		"shadow-ban: the user keeps sending, recipients get nothing")
+	flag.DurationVar(&f.duration, "for", 0,
+		"block duration, for example 24h, 0 for a permanent block")
+	flag.StringVar(&f.reason, "reason", "", "block reason")
+	flag.StringVar(&f.actor, "actor", os.Getenv("USER"),
+		"moderator name recorded in the audit trail")
 	flag.Parse()
-	name = strings.TrimSpace(name)
+	f.name = strings.TrimSpace(f.name)
+	f.reason = strings.TrimSpace(f.reason)
+	f.actor = strings.TrimSpace(f.actor)
 	return
 }
 
-func validateName(name string, log logger.Logger) error {
-	if name == "" {
+func validateFlags(f flags, log logger.Logger) error {
+	if f.name == "" {
 		return errors.New("name is empty")
 	}
+	if f.actor == "" {
+		return errors.New("actor is empty")
+	}
+	if f.duration < 0 {
+		return errors.New("duration is negative")
+	}
+	if f.duration != 0 && !f.blocked {
+		return errors.New("duration requires -blocked")
+	}
+	if f.shadow && !f.blocked {
+		return errors.New("shadow requires -blocked")
+	}
 
 	return nil
 }
 
 func createEmitter(log logger.Logger, brokers []string, topic string) *goka.Emitter {
-	codec := blocker.NewBlockValueCodec(log)
+	codec := blocker.NewEventCodec(log)
 	e, err := goka.NewEmitter(brokers, goka.Stream(topic), codec)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to construct emitter")
@@ -22,6 +22,7 @@ func main() {
 	logger := logger.New(config.logLevel)
 
 	word, change := getFlags()
+	word = censor.Key(word)
 
 	validateFlags(logger, word, change)
 
@@ -47,7 +48,7 @@ func loadConfig() config {
 }
 
 func getFlags() (word string, change string) {
-	flag.StringVar(&word, "word", "", "replaced word")
+	flag.StringVar(&word, "word", "", "replaced word or phrase")
 	flag.StringVar(&change, "change", "", "change on")
 	flag.Parse()
 
@@ -79,7 +80,7 @@ func validateFlags(log logger.Logger, word, change string) {
 
 func validateWord(word string, log logger.Logger) error {
 	if word == "" {
-		return errors.New("word is empty")
+		return errors.New("word has no letters or digits")
 	}
 	return nil
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/niksmo/messaging/internal/auth"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+type config struct {
+	logLevel   string
+	authSecret string
+}
+
+func main() {
+	config := loadConfig()
+	logger := logger.New(config.logLevel)
+
+	name, ttl, admin := getFlags()
+
+	if err := validateFlags(name, ttl); err != nil {
+		logger.Error().Err(err).Send()
+		flag.CommandLine.Usage()
+		os.Exit(1)
+	}
+
+	signer, err := auth.NewSigner([]byte(config.authSecret))
+	if err != nil {
+		logger.Fatal().Err(err).Msg(
+			"failed to construct signer, set MESSAGING_AUTH_SECRET")
+	}
+
+	claims := auth.Claims{Subject: name, Admin: admin}
+	if ttl != 0 {
+		claims.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	token, err := signer.Sign(claims)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to sign token")
+	}
+	logger.Info().Str("name", name).Dur("ttl", ttl).Bool("admin", admin).Send()
+	fmt.Println(token)
+}
+
+func loadConfig() config {
+	return config{
+		logLevel:   "info",
+		authSecret: os.Getenv("MESSAGING_AUTH_SECRET"),
+	}
+}
+
+func getFlags() (name string, ttl time.Duration, admin bool) {
+	flag.StringVar(&name, "name", "", "user name")
+	flag.BoolVar(&admin, "admin", false, "grant access to the admin API")
+	flag.DurationVar(&ttl, "ttl", 24*time.Hour, "token lifetime, 0 for no expiry")
+	flag.Parse()
+	name = strings.TrimSpace(name)
+	return
+}
+
+func validateFlags(name string, ttl time.Duration) error {
+	if name == "" {
+		return errors.New("name is empty")
+	}
+	if ttl < 0 {
+		return errors.New("ttl is negative")
+	}
+	return nil
+}
@@ -4,16 +4,24 @@ import (
 	"context"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/niksmo/messaging/internal/processor"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 type config struct {
-	logLevel string
-	brokers  []string
-	npart    int
-	rFactor  int
+	logLevel    string
+	brokers     []string
+	npart       int
+	rFactor     int
+	metricsAddr string
+	dedupWindow time.Duration
+	maxMessages int
+	maxAge      time.Duration
+	archive     bool
+
+	blockCleanupInterval time.Duration
 }
 
 func main() {
@@ -23,8 +31,19 @@ func main() {
 	config := laodConfig()
 	logger := logger.New(config.logLevel)
 
+	opts := []processor.Option{
+		processor.WithMetricsAddr(config.metricsAddr),
+		processor.WithDedupWindow(config.dedupWindow),
+		processor.WithRetention(config.maxMessages, config.maxAge),
+		processor.WithBlockCleanupInterval(config.blockCleanupInterval),
+	}
+	if config.archive {
+		opts = append(opts, processor.WithArchive())
+	}
+
 	processor.Run(sigCatcher, logger,
-		processor.WithOptions(config.brokers, config.npart, config.rFactor))
+		processor.WithOptions(
+			config.brokers, config.npart, config.rFactor, opts...))
 }
 
 func signalCatcher() (context.Context, context.CancelFunc) {
@@ -44,7 +63,14 @@ func laodConfig() config {
 			"127.0.0.1:29094",
 			"127.0.0.1:39094",
 		},
-		npart:   3,
-		rFactor: 2,
+		npart:       3,
+		rFactor:     2,
+		metricsAddr: "127.0.0.1:8001",
+		dedupWindow: 10 * time.Minute,
+		maxMessages: 1000,
+		maxAge:      30 * 24 * time.Hour,
+		archive:     true,
+
+		blockCleanupInterval: time.Minute,
 	}
 }
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/niksmo/messaging/internal/messaging"
 	"github.com/niksmo/messaging/internal/processor/collector"
+	"github.com/niksmo/messaging/internal/processor/conversation"
 	"github.com/niksmo/messaging/internal/server"
 	"github.com/niksmo/messaging/pkg/logger"
 )
@@ -18,9 +20,13 @@ type config struct {
 	brokers           []string
 	outTopic          string
 	inTopic           string
+	convTopic         string
 	partitions        int
 	replicationFactor int
 	closeTimeout      time.Duration
+	authSecret        string
+	rateLimit         float64
+	rateBurst         int
 }
 
 func main() {
@@ -57,9 +63,13 @@ func laodConfig() config {
 		},
 		outTopic:          messaging.Stream,
 		inTopic:           string(collector.Group),
+		convTopic:         string(conversation.Group),
 		partitions:        3,
 		replicationFactor: 2,
 		closeTimeout:      5 * time.Second,
+		authSecret:        os.Getenv("MESSAGING_AUTH_SECRET"),
+		rateLimit:         5,
+		rateBurst:         10,
 	}
 }
 
@@ -69,6 +79,9 @@ func createApp(logger logger.Logger, cfg config) *server.App {
 		server.WithBrokers(cfg.brokers),
 		server.WithOutTopic(cfg.outTopic),
 		server.WithInTopic(cfg.inTopic),
+		server.WithConversationTopic(cfg.convTopic),
+		server.WithAuthSecret(cfg.authSecret),
+		server.WithRateLimit(cfg.rateLimit, cfg.rateBurst),
 	}
 
 	app, err := server.New(logger, serverOpts...)
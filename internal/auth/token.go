@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmptySecret  = errors.New("secret is empty")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+var encoding = base64.RawURLEncoding
+
+type Claims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	Admin     bool   `json:"adm,omitempty"`
+}
+
+// Signer issues and verifies tokens of the form
+// base64url(claims JSON) "." base64url(HMAC-SHA256(claims JSON)),
+// so any holder of the secret can verify them offline.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) (*Signer, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	return &Signer{secret}, nil
+}
+
+func (s *Signer) Sign(c Claims) (string, error) {
+	const op = "Signer.Sign"
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return encoding.EncodeToString(payload) + "." +
+		encoding.EncodeToString(s.mac(payload)), nil
+}
+
+func (s *Signer) Verify(token string) (Claims, error) {
+	var c Claims
+
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return c, ErrInvalidToken
+	}
+
+	payload, err := encoding.DecodeString(encPayload)
+	if err != nil {
+		return c, ErrInvalidToken
+	}
+
+	sig, err := encoding.DecodeString(encSig)
+	if err != nil || !hmac.Equal(sig, s.mac(payload)) {
+		return c, ErrInvalidToken
+	}
+
+	if err := json.Unmarshal(payload, &c); err != nil || c.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if c.ExpiresAt != 0 && time.Now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+	return c, nil
+}
+
+func (s *Signer) mac(payload []byte) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write(payload)
+	return m.Sum(nil)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSignerEmptySecret(t *testing.T) {
+	if _, err := NewSigner(nil); !errors.Is(err, ErrEmptySecret) {
+		t.Fatalf("err = %v, want %v", err, ErrEmptySecret)
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	s, err := NewSigner([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSigner([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(s *Signer, c Claims) string {
+		token, err := s.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(s, Claims{Subject: "jack"})
+	payload, sig, _ := strings.Cut(valid, ".")
+	forged := encoding.EncodeToString([]byte(`{"sub":"david"}`))
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Claims
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: valid,
+			want:  Claims{Subject: "jack"},
+		},
+		{
+			name:  "with expiry",
+			token: sign(s, Claims{Subject: "jack", ExpiresAt: future}),
+			want:  Claims{Subject: "jack", ExpiresAt: future},
+		},
+		{
+			name: "admin with expiry",
+			token: sign(s,
+				Claims{Subject: "root", Admin: true, ExpiresAt: future}),
+			want: Claims{Subject: "root", Admin: true, ExpiresAt: future},
+		},
+		{
+			name:    "expired",
+			token:   sign(s, Claims{Subject: "jack", ExpiresAt: past}),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "signed with another secret",
+			token:   sign(other, Claims{Subject: "jack"}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "tampered payload",
+			token:   forged + "." + sig,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "missing signature",
+			token:   payload,
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "malformed signature",
+			token:   payload + ".!",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "empty subject",
+			token:   sign(s, Claims{}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "empty",
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("claims = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
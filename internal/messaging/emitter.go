@@ -1,6 +1,8 @@
 package messaging
 
 import (
+	"sync"
+
 	"github.com/lovoo/goka"
 	"github.com/niksmo/messaging/pkg/logger"
 )
@@ -24,3 +26,26 @@ func NewEmitter(
 func (e *Emitter) Emit(key string, msg Message) error {
 	return e.ge.EmitSync(key, msg)
 }
+
+// EmitBatch emits msgs asynchronously and waits for all of them to be
+// acknowledged. The returned slice holds the result of each message.
+func (e *Emitter) EmitBatch(key string, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+
+	var wg sync.WaitGroup
+	for i, msg := range msgs {
+		p, err := e.ge.Emit(key, msg)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		p.Then(func(err error) {
+			errs[i] = err
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -1,17 +1,89 @@
 package messaging
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 const Stream = "messages"
 
+// Message is stored as JSON without tags, so records written before a
+// field was added still decode with that field left zero. IdempotencyKey
+// is the client key the filter deduplicates retries on. Shadowed messages
+// come from shadow-banned senders and are visible only to them.
 type Message struct {
+	ID                string
+	IdempotencyKey    string `json:",omitempty"`
 	From, To, Content string
+	Seq               int64
+	CreatedAt         time.Time
+	DeliveredAt       time.Time
+	ReadAt            time.Time
+	Shadowed          bool `json:",omitempty"`
+}
+
+// VisibleTo reports whether user may see the message.
+func (m Message) VisibleTo(user string) bool {
+	return !m.Shadowed || m.From == user
+}
+
+// Backfill numbers the messages of the owner's inbox stored before Seq
+// existed and identifies the ones stored before ID existed. Unnumbered
+// messages precede every sequenced one, and each of them took a loop
+// topic offset before the first sequenced one did, so numbering them from
+// one keeps Seq increasing. Their IDs are derived from Seq. It reports
+// whether any message changed.
+func Backfill(owner string, ml []Message) bool {
+	changed := false
+	for i := range ml {
+		if ml[i].Seq == 0 {
+			ml[i].Seq = int64(i) + 1
+			changed = true
+		}
+		if ml[i].ID == "" {
+			ml[i].ID = legacyID(owner, ml[i].Seq)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func legacyID(owner string, seq int64) string {
+	sum := sha256.Sum256([]byte(
+		"legacy\x00" + owner + "\x00" + strconv.FormatInt(seq, 10)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// NewID returns a random message ID.
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Digest identifies the recipient and the content of the message so that
+// a retry with the same idempotency key can be told from a different
+// message reusing it.
+func (m Message) Digest() string {
+	sum := sha256.Sum256([]byte(m.To + "\x00" + m.Content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ConversationKey returns the same key for both directions of a
+// conversation by ordering the pair of user names.
+func ConversationKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "\x00" + b
 }
 
 type MessageCodec struct {
@@ -0,0 +1,102 @@
+package messaging
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+func TestBackfill(t *testing.T) {
+	ml := []Message{
+		{Content: "before seq"},
+		{Content: "before seq"},
+		{Content: "before id", Seq: 7},
+		{Content: "current", Seq: 9, ID: "abc"},
+	}
+
+	if !Backfill("jack", ml) {
+		t.Fatal("Backfill reported no change")
+	}
+
+	wantSeqs := []int64{1, 2, 7, 9}
+	ids := make(map[string]bool)
+	for i, m := range ml {
+		if m.Seq != wantSeqs[i] {
+			t.Errorf("message %d: seq = %d, want %d", i, m.Seq, wantSeqs[i])
+		}
+		if m.ID == "" || ids[m.ID] {
+			t.Errorf("message %d: id %q is empty or repeated", i, m.ID)
+		}
+		ids[m.ID] = true
+	}
+	if ml[3].ID != "abc" {
+		t.Errorf("existing id changed to %q", ml[3].ID)
+	}
+
+	again := append([]Message(nil), ml...)
+	if Backfill("jack", again) {
+		t.Error("second Backfill reported a change")
+	}
+
+	other := []Message{{Content: "before seq"}}
+	Backfill("david", other)
+	if other[0].ID == ml[0].ID {
+		t.Error("legacy ids of different inboxes collide")
+	}
+}
+
+func TestMessageCodecDecodesLegacyMessage(t *testing.T) {
+	c := NewMessageCodec(logger.New("error"))
+
+	got, err := c.Decode([]byte(
+		`{"From":"david","To":"jack","Content":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Message{From: "david", To: "jack", Content: "hi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded = %#v, want %#v", got, want)
+	}
+}
+
+func TestMessageCodecRoundTrip(t *testing.T) {
+	c := NewMessageCodec(logger.New("error"))
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		m    Message
+	}{
+		{"zero", Message{}},
+		{"full", Message{
+			ID:             "abc",
+			IdempotencyKey: "k1",
+			From:           "david",
+			To:             "jack",
+			Content:        "hi",
+			Seq:            7,
+			CreatedAt:      at,
+			DeliveredAt:    at.Add(time.Second),
+			ReadAt:         at.Add(time.Minute),
+			Shadowed:       true,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := c.Encode(tt.m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.m) {
+				t.Fatalf("decoded = %#v, want %#v", got, tt.m)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package messaging
+
+import "sync"
+
+type Subscription struct {
+	C   <-chan struct{}
+	c   chan struct{}
+	key string
+	n   *notifier
+}
+
+func (s *Subscription) Close() {
+	s.n.unsubscribe(s)
+}
+
+type notifier struct {
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{subs: make(map[string]map[*Subscription]struct{})}
+}
+
+func (n *notifier) subscribe(key string) *Subscription {
+	c := make(chan struct{}, 1)
+	s := &Subscription{C: c, c: c, key: key, n: n}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subs[key] == nil {
+		n.subs[key] = make(map[*Subscription]struct{})
+	}
+	n.subs[key][s] = struct{}{}
+	return s
+}
+
+func (n *notifier) unsubscribe(s *Subscription) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.subs[s.key], s)
+	if len(n.subs[s.key]) == 0 {
+		delete(n.subs, s.key)
+	}
+}
+
+// notify never blocks: a pending signal already tells the subscriber
+// to re-read the key, so extra signals are dropped.
+func (n *notifier) notify(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for s := range n.subs[key] {
+		select {
+		case s.c <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	RejectReasonSenderBlocked = "sender_blocked"
+	RejectReasonKeyConflict   = "idempotency_key_conflict"
+)
+
+// Rejection tells a sender that their message was not delivered.
+type Rejection struct {
+	MessageID  string
+	To         string
+	Reason     string
+	RejectedAt time.Time
+}
+
+type RejectionCodec struct {
+	log logger.Logger
+}
+
+func NewRejectionCodec(l logger.Logger) RejectionCodec {
+	return RejectionCodec{l}
+}
+
+func (c RejectionCodec) Encode(value any) ([]byte, error) {
+	const op = "RejectionCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(Rejection); !ok {
+		log.Error().Msg("value is not rejection type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("not rejection type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal rejection")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c RejectionCodec) Decode(data []byte) (any, error) {
+	const op = "RejectionCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var r Rejection
+	if err := json.Unmarshal(data, &r); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal rejection")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return r, nil
+}
+
+type RejectionListCodec struct {
+	log logger.Logger
+}
+
+func NewRejectionListCodec(l logger.Logger) RejectionListCodec {
+	return RejectionListCodec{l}
+}
+
+func (c RejectionListCodec) Encode(value any) ([]byte, error) {
+	const op = "RejectionListCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.([]Rejection); !ok {
+		log.Error().Msg("value is not rejection list type")
+		return nil, fmt.Errorf(
+			"%s: %w", op, errors.New("not rejection list type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal rejection list")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c RejectionListCodec) Decode(data []byte) (any, error) {
+	const op = "RejectionListCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var rl []Rejection
+	if err := json.Unmarshal(data, &rl); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal rejection list")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return rl, nil
+}
@@ -4,24 +4,28 @@ import (
 	"context"
 
 	"github.com/lovoo/goka"
+	"github.com/lovoo/goka/storage"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 type View struct {
 	gv *goka.View
+	n  *notifier
 }
 
 func NewView(
 	logger logger.Logger, brokers []string, topic string,
 ) (*View, error) {
+	n := newNotifier()
 	gv, err := goka.NewView(
 		brokers, goka.GroupTable(goka.Group(topic)), NewMessageListCodec(logger),
+		goka.WithViewCallback(updateCallback(n)),
 	)
 
 	if err != nil {
 		return nil, err
 	}
-	return &View{gv}, nil
+	return &View{gv, n}, nil
 }
 
 func (v *View) Run(ctx context.Context) error {
@@ -31,3 +35,27 @@ func (v *View) Run(ctx context.Context) error {
 func (v *View) Get(key string) (any, error) {
 	return v.gv.Get(key)
 }
+
+func (v *View) Recovered() bool {
+	return v.gv.Recovered()
+}
+
+func (v *View) ObserveStateChanges() *goka.StateChangeObserver {
+	return v.gv.ObserveStateChanges()
+}
+
+func (v *View) Subscribe(key string) *Subscription {
+	return v.n.subscribe(key)
+}
+
+func updateCallback(n *notifier) goka.UpdateCallback {
+	return func(
+		ctx goka.UpdateContext, s storage.Storage, key string, value []byte,
+	) error {
+		if err := goka.DefaultUpdate(ctx, s, key, value); err != nil {
+			return err
+		}
+		n.notify(key)
+		return nil
+	}
+}
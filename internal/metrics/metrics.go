@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lovoo/goka"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "messaging"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latencies by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	EmitErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "emit_errors_total",
+		Help:      "Messages the server failed to emit.",
+	})
+
+	RateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "server",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the rate limiter.",
+	})
+
+	MessagesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_received_total",
+		Help:      "Messages received by the filter.",
+	})
+
+	MessagesBlocked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_blocked_total",
+		Help:      "Messages dropped because the sender is blocked.",
+	})
+
+	MessagesShadowed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_shadowed_total",
+		Help:      "Messages of shadow-banned senders hidden from recipients.",
+	})
+
+	MessagesBlockedByRecipient = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_blocked_by_recipient_total",
+		Help:      "Messages dropped because the recipient blocked the sender.",
+	})
+
+	MessagesDuplicate = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_duplicate_total",
+		Help:      "Messages dropped as retries of an already seen ID.",
+	})
+
+	MessagesCensored = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "messages_censored_total",
+		Help:      "Messages with censored content.",
+	})
+
+	MessagesEvicted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "collector",
+		Name:      "messages_evicted_total",
+		Help:      "Messages evicted from inboxes by the retention policy.",
+	})
+
+	InboxSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "collector",
+		Name:      "inbox_size",
+		Help:      "Inbox list sizes after a message is collected.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	})
+
+	GokaState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "goka_state",
+		Help:      "Current goka processor or view state by component.",
+	}, []string{"component"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveState mirrors goka state changes of a processor or view into
+// GokaState until ctx is done.
+func ObserveState(
+	ctx context.Context, component string, o *goka.StateChangeObserver,
+) {
+	defer o.Stop()
+	g := GokaState.WithLabelValues(component)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case state, ok := <-o.C():
+			if !ok {
+				return
+			}
+			g.Set(float64(state))
+		}
+	}
+}
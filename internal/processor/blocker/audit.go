@@ -0,0 +1,83 @@
+package blocker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const auditGroup goka.Group = "blocker-audit-group"
+
+// AuditTable keeps the full moderation history of every user.
+var AuditTable goka.Table = goka.GroupTable(auditGroup)
+
+// History lists the moderation events of a user in the order they were
+// applied.
+type History []Event
+
+func makeAuditGroupGraph(logger logger.Logger) *goka.GroupGraph {
+	return goka.DefineGroup(
+		auditGroup,
+		goka.Input(Stream, NewEventCodec(logger), auditCallback(logger)),
+		goka.Persist(NewHistoryCodec(logger)),
+	)
+}
+
+func auditCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "blocker.auditCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		e, ok := msg.(Event)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+		if e.Timestamp.IsZero() {
+			e.Timestamp = ctx.Timestamp()
+		}
+
+		h, _ := ctx.Value().(History)
+		ctx.SetValue(append(h, e))
+	}
+}
+
+type HistoryCodec struct {
+	log logger.Logger
+}
+
+func NewHistoryCodec(log logger.Logger) HistoryCodec {
+	return HistoryCodec{log}
+}
+
+func (c HistoryCodec) Encode(value any) ([]byte, error) {
+	const op = "HistoryCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(History); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal history")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c HistoryCodec) Decode(data []byte) (any, error) {
+	const op = "HistoryCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var h History
+	if err := json.Unmarshal(data, &h); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal history")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return h, nil
+}
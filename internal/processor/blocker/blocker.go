@@ -2,12 +2,16 @@ package blocker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/metrics"
 	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -15,11 +19,35 @@ const (
 	Stream goka.Stream = "blocked_users"
 )
 
+const defaultCleanupInterval = time.Minute
+
 var Table goka.Table = goka.GroupTable(group)
 
-func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+type options struct {
+	cleanupInterval time.Duration
+}
+
+type Option func(*options)
+
+// WithCleanupInterval sets how often expired blocks are cleared.
+func WithCleanupInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.cleanupInterval = d
+		}
+	}
+}
+
+func Run(
+	ctx context.Context, logger logger.Logger, brokers []string, opts ...Option,
+) error {
 	const op = "blocker.Run"
 
+	o := options{cleanupInterval: defaultCleanupInterval}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	g := makeGroupGraph(logger)
 
 	p, err := goka.NewProcessor(brokers, g)
@@ -27,33 +55,113 @@ func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return p.Run(ctx)
+	ap, err := goka.NewProcessor(brokers, makeAuditGroupGraph(logger))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c, err := newCleaner(logger, brokers, o.cleanupInterval)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+	go metrics.ObserveState(
+		ctx, string(auditGroup), ap.StateReader().ObserveStateChange())
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error { return p.Run(ctx) })
+	eg.Go(func() error { return ap.Run(ctx) })
+	eg.Go(func() error { return c.run(ctx) })
+	return eg.Wait()
 }
 
 func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
-	blockValueCodec := NewBlockValueCodec(logger)
 	return goka.DefineGroup(
 		group,
-		goka.Input(Stream, blockValueCodec, processCallback(logger)),
-		goka.Persist(blockValueCodec),
+		goka.Input(Stream, NewEventCodec(logger), processCallback(logger)),
+		goka.Persist(NewBlockValueCodec(logger)),
 	)
 }
 
+// processCallback deletes the entry on unblock. An expire event deletes
+// the entry only if it is not active anymore at the record time, so that
+// a block renewed in the meantime survives and replays give the same
+// state.
 func processCallback(logger logger.Logger) goka.ProcessCallback {
 	const op = "blocker.processCallback"
 	log := logger.WithOp(op)
 
 	return func(ctx goka.Context, msg any) {
-		v, ok := msg.(BlockValue)
+		e, ok := msg.(Event)
 		if !ok {
 			log.Error().Type("msgType", msg).Msg("invalid msg type")
 			return
 		}
-		ctx.SetValue(v)
+		if e.Timestamp.IsZero() {
+			e.Timestamp = ctx.Timestamp()
+		}
+
+		switch e.Action {
+		case ActionBlock, ActionShadowBan:
+			ctx.SetValue(e.blockValue())
+		case ActionUnblock:
+			ctx.Delete()
+		case ActionExpire:
+			cur, ok := ctx.Value().(BlockValue)
+			if ok && !cur.Active(ctx.Timestamp()) {
+				ctx.Delete()
+			}
+		default:
+			log.Error().Str("action", e.Action).Msg("unknown action")
+		}
 	}
 }
 
-type BlockValue bool
+// State is the moderation state of a sender.
+type State string
+
+const (
+	StateActive       State = "active"
+	StateBlocked      State = "blocked"
+	StateShadowBanned State = "shadow_banned"
+)
+
+// BlockValue bans the sender it is keyed by from sending messages. A
+// shadow ban lets the sender keep sending but hides the messages from
+// recipients. A zero ExpiresAt makes the block permanent.
+type BlockValue struct {
+	Blocked   bool
+	Shadow    bool      `json:",omitempty"`
+	ExpiresAt time.Time `json:",omitzero"`
+	Reason    string    `json:",omitempty"`
+	Actor     string    `json:",omitempty"`
+	BlockedAt time.Time `json:",omitzero"`
+}
+
+// Active reports whether the block is in effect at t.
+func (v BlockValue) Active(t time.Time) bool {
+	return v.Blocked && (v.ExpiresAt.IsZero() || t.Before(v.ExpiresAt))
+}
+
+// Expired reports whether a temporary block has run out at t. Unlike
+// !Active it is false for permanent blocks and for values that do not
+// block at all, such as the legacy false ones.
+func (v BlockValue) Expired(t time.Time) bool {
+	return v.Blocked && !v.ExpiresAt.IsZero() && !t.Before(v.ExpiresAt)
+}
+
+// StateAt returns the state of the sender at t.
+func (v BlockValue) StateAt(t time.Time) State {
+	switch {
+	case !v.Active(t):
+		return StateActive
+	case v.Shadow:
+		return StateShadowBanned
+	}
+	return StateBlocked
+}
 
 type BlockValueCodec struct {
 	log logger.Logger
@@ -73,20 +181,28 @@ func (v BlockValueCodec) Encode(value any) ([]byte, error) {
 			op, errors.New("invalid value type"))
 	}
 
-	s := strconv.FormatBool(bool(vt))
-
-	return []byte(s), nil
+	b, err := json.Marshal(vt)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal block value")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
 }
 
+// Decode also accepts the plain boolean values written before blocks had
+// an expiry and a reason.
 func (v BlockValueCodec) Decode(data []byte) (any, error) {
 	const op = "BlockValueCodec.Decode"
 	log := v.log.WithOp(op)
 
-	bv, err := strconv.ParseBool(string(data))
-	if err != nil {
-		log.Error().Err(err).Msg("data could not be converted to a boolean value")
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if b, err := strconv.ParseBool(string(data)); err == nil {
+		return BlockValue{Blocked: b}, nil
+	}
 
+	var bv BlockValue
+	if err := json.Unmarshal(data, &bv); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal block value")
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return BlockValue(bv), nil
+	return bv, nil
 }
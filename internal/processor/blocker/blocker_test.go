@@ -0,0 +1,172 @@
+package blocker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+func TestBlockValueCodecDecode(t *testing.T) {
+	c := NewBlockValueCodec(logger.New("error"))
+	expires := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    BlockValue
+		wantErr bool
+	}{
+		{name: "legacy true", data: "true", want: BlockValue{Blocked: true}},
+		{name: "legacy false", data: "false", want: BlockValue{}},
+		{
+			name: "block value",
+			data: `{"Blocked":true,"Shadow":true,` +
+				`"ExpiresAt":"2025-01-02T00:00:00Z","Reason":"spam"}`,
+			want: BlockValue{
+				Blocked: true, Shadow: true, ExpiresAt: expires, Reason: "spam",
+			},
+		},
+		{name: "malformed", data: "{", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Decode([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decoded = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockValueAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		v           BlockValue
+		wantActive  bool
+		wantExpired bool
+		wantState   State
+	}{
+		{
+			name:      "legacy false",
+			v:         BlockValue{},
+			wantState: StateActive,
+		},
+		{
+			name:       "permanent block",
+			v:          BlockValue{Blocked: true},
+			wantActive: true,
+			wantState:  StateBlocked,
+		},
+		{
+			name:       "permanent shadow ban",
+			v:          BlockValue{Blocked: true, Shadow: true},
+			wantActive: true,
+			wantState:  StateShadowBanned,
+		},
+		{
+			name:       "temporary block",
+			v:          BlockValue{Blocked: true, ExpiresAt: now.Add(time.Hour)},
+			wantActive: true,
+			wantState:  StateBlocked,
+		},
+		{
+			name:        "block expiring now",
+			v:           BlockValue{Blocked: true, ExpiresAt: now},
+			wantExpired: true,
+			wantState:   StateActive,
+		},
+		{
+			name: "expired shadow ban",
+			v: BlockValue{
+				Blocked: true, Shadow: true, ExpiresAt: now.Add(-time.Hour),
+			},
+			wantExpired: true,
+			wantState:   StateActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.Active(now); got != tt.wantActive {
+				t.Errorf("Active = %t, want %t", got, tt.wantActive)
+			}
+			if got := tt.v.Expired(now); got != tt.wantExpired {
+				t.Errorf("Expired = %t, want %t", got, tt.wantExpired)
+			}
+			if got := tt.v.StateAt(now); got != tt.wantState {
+				t.Errorf("StateAt = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
+
+// gokaContext lets tableContext embed goka.Context, whose Context method
+// would otherwise clash with the embedded field name.
+type gokaContext = goka.Context
+
+type tableContext struct {
+	gokaContext
+	now   time.Time
+	value any
+}
+
+func (ctx *tableContext) Timestamp() time.Time { return ctx.now }
+
+func (ctx *tableContext) Value() any { return ctx.value }
+
+func (ctx *tableContext) SetValue(value any, _ ...goka.ContextOption) {
+	ctx.value = value
+}
+
+func (ctx *tableContext) Delete(_ ...goka.ContextOption) { ctx.value = nil }
+
+func TestProcessCallbackExpire(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	expire := Event{Action: ActionExpire, Actor: cleanerActor}
+
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{
+			name:  "expired block",
+			value: BlockValue{Blocked: true, ExpiresAt: now.Add(-time.Minute)},
+		},
+		{
+			name:  "renewed block",
+			value: BlockValue{Blocked: true, ExpiresAt: now.Add(time.Hour)},
+			want:  BlockValue{Blocked: true, ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			name:  "permanent block",
+			value: BlockValue{Blocked: true},
+			want:  BlockValue{Blocked: true},
+		},
+		{
+			name: "no block",
+		},
+	}
+
+	cb := processCallback(logger.New("error"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &tableContext{now: now, value: tt.value}
+			// Every cleaner instance emits its own expire event.
+			cb(ctx, expire)
+			cb(ctx, expire)
+			if !reflect.DeepEqual(ctx.value, tt.want) {
+				t.Fatalf("value = %#v, want %#v", ctx.value, tt.want)
+			}
+		})
+	}
+}
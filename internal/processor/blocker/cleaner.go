@@ -0,0 +1,104 @@
+package blocker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/sync/errgroup"
+)
+
+const cleanerActor = "blocker"
+
+// cleaner periodically emits expire events for the expired blocks, so
+// that the processor clears them from Table. Every instance runs a
+// cleaner over the whole table, so the same block may be expired more
+// than once; the processor ignores expire events for blocks that are
+// gone or still active.
+type cleaner struct {
+	log      logger.Logger
+	v        *goka.View
+	e        *goka.Emitter
+	interval time.Duration
+}
+
+func newCleaner(
+	logger logger.Logger, brokers []string, interval time.Duration,
+) (*cleaner, error) {
+	const op = "blocker.newCleaner"
+
+	v, err := goka.NewView(brokers, Table, NewBlockValueCodec(logger))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	e, err := goka.NewEmitter(brokers, Stream, NewEventCodec(logger))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &cleaner{logger, v, e, interval}, nil
+}
+
+func (c *cleaner) run(ctx context.Context) error {
+	const op = "cleaner.run"
+	log := c.log.WithOp(op)
+
+	defer c.e.Finish()
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return c.v.Run(ctx) })
+	g.Go(func() error {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			if !c.v.Recovered() {
+				continue
+			}
+			if err := c.clean(time.Now()); err != nil {
+				log.Error().Err(err).Msg("failed to clean expired blocks")
+			}
+		}
+	})
+	return g.Wait()
+}
+
+func (c *cleaner) clean(now time.Time) error {
+	const op = "cleaner.clean"
+	log := c.log.WithOp(op)
+
+	it, err := c.v.Iterator()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer it.Release()
+
+	for it.Next() {
+		value, err := it.Value()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		v, ok := value.(BlockValue)
+		if !ok || !v.Expired(now) {
+			continue
+		}
+		e := Event{
+			Action:    ActionExpire,
+			Actor:     cleanerActor,
+			Reason:    v.Reason,
+			Timestamp: now.UTC(),
+			ExpiresAt: v.ExpiresAt,
+		}
+		if err := c.e.EmitSync(it.Key(), e); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info().Str("name", it.Key()).Time(
+			"expiresAt", v.ExpiresAt).Msg("expired block cleared")
+	}
+	return it.Err()
+}
@@ -0,0 +1,98 @@
+package blocker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	ActionBlock     = "block"
+	ActionShadowBan = "shadow_ban"
+	ActionUnblock   = "unblock"
+	ActionExpire    = "expire"
+)
+
+// Event is a moderation action on the user it is keyed by. Expire is
+// emitted by the cleaner for blocks whose ExpiresAt has passed.
+type Event struct {
+	Action    string
+	Actor     string    `json:",omitempty"`
+	Reason    string    `json:",omitempty"`
+	Timestamp time.Time `json:",omitzero"`
+	ExpiresAt time.Time `json:",omitzero"`
+}
+
+// blockValue returns the table value a block event results in.
+func (e Event) blockValue() BlockValue {
+	return BlockValue{
+		Blocked:   true,
+		Shadow:    e.Action == ActionShadowBan,
+		ExpiresAt: e.ExpiresAt,
+		Reason:    e.Reason,
+		Actor:     e.Actor,
+		BlockedAt: e.Timestamp,
+	}
+}
+
+type EventCodec struct {
+	log logger.Logger
+}
+
+func NewEventCodec(log logger.Logger) EventCodec {
+	return EventCodec{log}
+}
+
+func (c EventCodec) Encode(value any) ([]byte, error) {
+	const op = "EventCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(Event); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal event")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+// Decode also accepts the plain booleans and block values the stream
+// carried before events, turning them into block and unblock events.
+func (c EventCodec) Decode(data []byte) (any, error) {
+	const op = "EventCodec.Decode"
+	log := c.log.WithOp(op)
+
+	if b, err := strconv.ParseBool(string(data)); err == nil {
+		return legacyEvent(b), nil
+	}
+
+	var e struct {
+		Event
+		Blocked *bool
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal event")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if e.Action == "" && e.Blocked != nil {
+		ev := legacyEvent(*e.Blocked)
+		ev.Reason, ev.ExpiresAt = e.Reason, e.ExpiresAt
+		return ev, nil
+	}
+	return e.Event, nil
+}
+
+func legacyEvent(blocked bool) Event {
+	if blocked {
+		return Event{Action: ActionBlock}
+	}
+	return Event{Action: ActionUnblock}
+}
@@ -0,0 +1,114 @@
+package blocker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+func TestEventCodecDecode(t *testing.T) {
+	c := NewEventCodec(logger.New("error"))
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	expires := at.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Event
+		wantErr bool
+	}{
+		{
+			name: "legacy true",
+			data: "true",
+			want: Event{Action: ActionBlock},
+		},
+		{
+			name: "legacy false",
+			data: "false",
+			want: Event{Action: ActionUnblock},
+		},
+		{
+			name: "legacy block value",
+			data: `{"Blocked":true,"ExpiresAt":"2025-01-01T13:00:00Z",` +
+				`"Reason":"spam"}`,
+			want: Event{
+				Action: ActionBlock, Reason: "spam", ExpiresAt: expires,
+			},
+		},
+		{
+			name: "legacy unblock value",
+			data: `{"Blocked":false}`,
+			want: Event{Action: ActionUnblock},
+		},
+		{
+			name: "event",
+			data: `{"Action":"shadow_ban","Actor":"root","Reason":"spam",` +
+				`"Timestamp":"2025-01-01T12:00:00Z",` +
+				`"ExpiresAt":"2025-01-01T13:00:00Z"}`,
+			want: Event{
+				Action:    ActionShadowBan,
+				Actor:     "root",
+				Reason:    "spam",
+				Timestamp: at,
+				ExpiresAt: expires,
+			},
+		},
+		{name: "malformed", data: "{", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Decode([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decoded = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventCodecRoundTrip(t *testing.T) {
+	c := NewEventCodec(logger.New("error"))
+	e := Event{
+		Action:    ActionExpire,
+		Actor:     cleanerActor,
+		Reason:    "spam",
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	data, err := c.Encode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Fatalf("decoded = %#v, want %#v", got, e)
+	}
+}
+
+// Legacy records carry no timestamp, so the processor dates them by the
+// record time.
+func TestProcessCallbackLegacyEvent(t *testing.T) {
+	c := NewEventCodec(logger.New("error"))
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e, err := c.Decode([]byte(`{"Blocked":true,"Reason":"spam"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &tableContext{now: at}
+	processCallback(logger.New("error"))(ctx, e)
+
+	want := BlockValue{Blocked: true, Reason: "spam", BlockedAt: at}
+	if !reflect.DeepEqual(ctx.value, want) {
+		t.Fatalf("value = %#v, want %#v", ctx.value, want)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/metrics"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
@@ -27,6 +28,9 @@ func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+
 	return p.Run(ctx)
 }
 
@@ -49,6 +53,10 @@ func processCallback(logger logger.Logger) goka.ProcessCallback {
 			log.Error().Type("msgType", msg).Msg("invalid msg type")
 			return
 		}
+		if v == "" {
+			ctx.Delete()
+			return
+		}
 		ctx.SetValue(v)
 	}
 }
@@ -0,0 +1,124 @@
+package censor
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Matcher replaces censored words and phrases in texts. It is built once
+// from the table contents and is safe for concurrent use.
+//
+// Phrases are matched word by word, ignoring the spacing and punctuation
+// between their words. Where entries overlap, the match starting at the
+// leftmost word wins, and of the matches starting there the longest one.
+// Replaced words are not matched again, so in "buy now later" with the
+// entries "buy now" and "now later" only "buy now" is replaced.
+type Matcher struct {
+	root *node
+}
+
+type node struct {
+	children    map[string]*node
+	replacement string
+	terminal    bool
+}
+
+// NewMatcher builds a matcher from a table of keys to replacements. Keys
+// stored before they were folded by Key are folded here, and where such a
+// key folds to a stored key, the stored one wins.
+func NewMatcher(entries map[string]string) *Matcher {
+	m := &Matcher{&node{}}
+	keys := slices.Sorted(maps.Keys(entries))
+	for _, folded := range []bool{false, true} {
+		for _, key := range keys {
+			if fk := Key(key); (fk == key) == folded {
+				m.insert(fk, entries[key])
+			}
+		}
+	}
+	return m
+}
+
+func (m *Matcher) insert(key, replacement string) {
+	words := strings.Fields(key)
+	if len(words) == 0 {
+		return
+	}
+
+	n := m.root
+	for _, w := range words {
+		child, ok := n.children[w]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*node)
+			}
+			child = &node{}
+			n.children[w] = child
+		}
+		n = child
+	}
+	n.terminal = true
+	n.replacement = replacement
+}
+
+// Replace returns text with every censored phrase replaced and reports
+// whether anything was replaced.
+func (m *Matcher) Replace(text string) (string, bool) {
+	if m == nil || len(m.root.children) == 0 {
+		return text, false
+	}
+
+	segs := Segments(text)
+	keys := make([]string, len(segs))
+	for i, seg := range segs {
+		if seg.Word {
+			keys[i] = fold(seg.Text)
+		}
+	}
+
+	var b strings.Builder
+	replaced := false
+	for i := 0; i < len(segs); {
+		end, replacement, ok := m.match(segs, keys, i)
+		if !ok {
+			b.WriteString(segs[i].Text)
+			i++
+			continue
+		}
+
+		var original strings.Builder
+		for _, seg := range segs[i:end] {
+			original.WriteString(seg.Text)
+		}
+		b.WriteString(MatchCase(original.String(), replacement))
+		replaced = true
+		i = end
+	}
+	return b.String(), replaced
+}
+
+// match returns the end of the longest phrase starting at segment start.
+func (m *Matcher) match(
+	segs []Segment, keys []string, start int,
+) (end int, replacement string, ok bool) {
+	if !segs[start].Word {
+		return 0, "", false
+	}
+
+	n := m.root
+	for i := start; i < len(segs); i++ {
+		if !segs[i].Word {
+			continue
+		}
+
+		n = n.children[keys[i]]
+		if n == nil {
+			break
+		}
+		if n.terminal {
+			end, replacement, ok = i+1, n.replacement, true
+		}
+	}
+	return end, replacement, ok
+}
@@ -0,0 +1,121 @@
+package censor
+
+import "testing"
+
+func TestMatcherReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		in      string
+		want    string
+		wantOK  bool
+	}{
+		{
+			name: "no entries",
+			in:   "buy now",
+			want: "buy now",
+		},
+		{
+			name:    "single word keeps punctuation and case",
+			entries: map[string]string{"apple": "orange"},
+			in:      "Apple, APPLE and apple!",
+			want:    "Orange, ORANGE and orange!",
+			wantOK:  true,
+		},
+		{
+			name:    "part of a word does not match",
+			entries: map[string]string{"apple": "orange"},
+			in:      "pineapple applesauce",
+			want:    "pineapple applesauce",
+		},
+		{
+			name:    "phrase across spacing and punctuation",
+			entries: map[string]string{"buy now": "***"},
+			in:      "Buy,\n  now! please",
+			want:    "***! please",
+			wantOK:  true,
+		},
+		{
+			name:    "incomplete phrase",
+			entries: map[string]string{"buy now": "***"},
+			in:      "buy later",
+			want:    "buy later",
+		},
+		{
+			name: "nested phrases prefer the longest",
+			entries: map[string]string{
+				"buy": "get", "buy now": "***", "buy now cheap": "###",
+			},
+			in:     "buy now cheap, buy now, buy",
+			want:   "###, ***, get",
+			wantOK: true,
+		},
+		{
+			name: "longest falls back to a shorter match",
+			entries: map[string]string{
+				"buy now": "***", "buy now cheap pills": "###",
+			},
+			in:     "buy now cheap stuff",
+			want:   "*** cheap stuff",
+			wantOK: true,
+		},
+		{
+			name: "overlapping phrases prefer the leftmost",
+			entries: map[string]string{
+				"buy now": "***", "now later": "###",
+			},
+			in:     "buy now later",
+			want:   "*** later",
+			wantOK: true,
+		},
+		{
+			name: "leftmost wins over a longer later match",
+			entries: map[string]string{
+				"a b": "1", "b c d": "2",
+			},
+			in:     "a b c d",
+			want:   "1 c d",
+			wantOK: true,
+		},
+		{
+			name:    "legacy keys are folded",
+			entries: map[string]string{"Damn!": "darn", "Buy  Now": "***"},
+			in:      "damn, buy now",
+			want:    "darn, ***",
+			wantOK:  true,
+		},
+		{
+			name: "folded key wins over a legacy one",
+			entries: map[string]string{
+				"Apple": "pear", "APPLE": "plum", "apple": "orange",
+			},
+			in:     "apple",
+			want:   "orange",
+			wantOK: true,
+		},
+		{
+			name:    "empty key is ignored",
+			entries: map[string]string{"!!": "x", "apple": "orange"},
+			in:      "!! apple",
+			want:    "!! orange",
+			wantOK:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewMatcher(tt.entries).Replace(tt.in)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("Replace(%q) = %q, %t, want %q, %t",
+					tt.in, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNilMatcherReplace(t *testing.T) {
+	var m *Matcher
+	if got, ok := m.Replace("apple"); got != "apple" || ok {
+		t.Fatalf("Replace = %q, %t, want %q, false", got, ok, "apple")
+	}
+}
@@ -0,0 +1,90 @@
+package censor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/cases"
+)
+
+// Segment is a part of a text between two Unicode word boundaries. Word
+// segments contain letters or digits, the others are spacing and
+// punctuation.
+type Segment struct {
+	Text string
+	Word bool
+}
+
+// Segments splits s on Unicode word boundaries. Joining the texts of the
+// segments gives s back.
+func Segments(s string) []Segment {
+	var segs []Segment
+	state := -1
+	for len(s) > 0 {
+		var word string
+		word, s, state = uniseg.FirstWordInString(s, state)
+		segs = append(segs, Segment{Text: word, Word: isWord(word)})
+	}
+	return segs
+}
+
+func isWord(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}) >= 0
+}
+
+// Key returns the censor table key of a word or phrase: its case-folded
+// words joined by single spaces, so that words differing only in case,
+// spacing or punctuation match the same entry.
+func Key(phrase string) string {
+	var words []string
+	for _, seg := range Segments(phrase) {
+		if seg.Word {
+			words = append(words, fold(seg.Text))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func fold(word string) string {
+	return cases.Fold().String(word)
+}
+
+// MatchCase gives the replacement the case of the original word when the
+// original is all upper case or capitalized, and keeps it as is
+// otherwise.
+func MatchCase(original, replacement string) string {
+	var upper, lower int
+	for _, r := range original {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	switch {
+	case upper > 1 && lower == 0:
+		return strings.ToUpper(replacement)
+	case upper > 0 && startsUpper(original):
+		return capitalize(replacement)
+	}
+	return replacement
+}
+
+func startsUpper(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsUpper(r)
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToTitle(r)) + s[size:]
+}
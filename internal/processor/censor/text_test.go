@@ -0,0 +1,94 @@
+package censor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantWords []string
+	}{
+		{"", nil},
+		{"I like green apple", []string{"I", "like", "green", "apple"}},
+		{"Apple, APPLE and apple!", []string{"Apple", "APPLE", "and", "apple"}},
+		{"don't\nstop", []string{"don't", "stop"}},
+		{"Привет, мир", []string{"Привет", "мир"}},
+		{"version 2.0 out", []string{"version", "2.0", "out"}},
+		{"... !!", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			segs := Segments(tt.in)
+
+			var b strings.Builder
+			var words []string
+			for _, seg := range segs {
+				b.WriteString(seg.Text)
+				if seg.Word {
+					words = append(words, seg.Text)
+				}
+			}
+			if b.String() != tt.in {
+				t.Errorf("joined segments = %q, want %q", b.String(), tt.in)
+			}
+			if !slices.Equal(words, tt.wantWords) {
+				t.Errorf("words = %q, want %q", words, tt.wantWords)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"apple", "apple"},
+		{"Apple", "apple"},
+		{"  APPLE! ", "apple"},
+		{"Buy   NOW", "buy now"},
+		{"buy, now!", "buy now"},
+		{"Straße", "strasse"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Key(tt.in); got != tt.want {
+				t.Fatalf("Key(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchCase(t *testing.T) {
+	tests := []struct {
+		original    string
+		replacement string
+		want        string
+	}{
+		{"apple", "orange", "orange"},
+		{"Apple", "orange", "Orange"},
+		{"APPLE", "orange", "ORANGE"},
+		{"aPPLE", "orange", "orange"},
+		{"A", "orange", "Orange"},
+		{"Buy NOW", "***", "***"},
+		{"BUY NOW", "later", "LATER"},
+		{"Яблоко", "апельсин", "Апельсин"},
+		{"apple", "Orange", "Orange"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.original, func(t *testing.T) {
+			got := MatchCase(tt.original, tt.replacement)
+			if got != tt.want {
+				t.Fatalf("MatchCase(%q, %q) = %q, want %q",
+					tt.original, tt.replacement, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package censor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/lovoo/goka/storage"
+	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	minRebuildBackoff = 100 * time.Millisecond
+	maxRebuildBackoff = 10 * time.Second
+)
+
+// MatcherView keeps a Matcher built from the contents of Table up to date.
+type MatcherView struct {
+	log     logger.Logger
+	v       *goka.View
+	m       atomic.Pointer[Matcher]
+	updates chan struct{}
+	ready   chan struct{}
+	once    sync.Once
+}
+
+func NewMatcherView(
+	logger logger.Logger, brokers []string,
+) (*MatcherView, error) {
+	const op = "censor.NewMatcherView"
+
+	mv := &MatcherView{
+		log:     logger,
+		updates: make(chan struct{}, 1),
+		ready:   make(chan struct{}),
+	}
+	v, err := goka.NewView(brokers, Table, NewCensorValueCodec(logger),
+		goka.WithViewCallback(mv.updateCallback))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	mv.v = v
+	return mv, nil
+}
+
+// Run runs the view and rebuilds the matcher after every change of the
+// table once the view has recovered. Failed rebuilds are retried with
+// backoff, so that the first matcher is built without waiting for a
+// change.
+func (mv *MatcherView) Run(ctx context.Context) error {
+	const op = "MatcherView.Run"
+	log := mv.log.WithOp(op)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return mv.v.Run(ctx) })
+	g.Go(func() error {
+		select {
+		case <-mv.v.WaitRunning():
+		case <-ctx.Done():
+			return nil
+		}
+
+		backoff := minRebuildBackoff
+		for {
+			var retry <-chan time.Time
+			if err := mv.rebuild(); err != nil {
+				log.Error().Err(err).Dur(
+					"retryIn", backoff).Msg("failed to rebuild matcher")
+				retry = time.After(backoff)
+				backoff = min(2*backoff, maxRebuildBackoff)
+			} else {
+				backoff = minRebuildBackoff
+				mv.once.Do(func() { close(mv.ready) })
+			}
+
+			select {
+			case <-mv.updates:
+			case <-retry:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	return g.Wait()
+}
+
+// Ready is closed once the first matcher is built from the recovered
+// table.
+func (mv *MatcherView) Ready() <-chan struct{} {
+	return mv.ready
+}
+
+func (mv *MatcherView) Matcher() *Matcher {
+	return mv.m.Load()
+}
+
+func (mv *MatcherView) rebuild() error {
+	const op = "MatcherView.rebuild"
+
+	it, err := mv.v.Iterator()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer it.Release()
+
+	entries := make(map[string]string)
+	for it.Next() {
+		value, err := it.Value()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if replacement, ok := value.(string); ok {
+			entries[it.Key()] = replacement
+		}
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	mv.m.Store(NewMatcher(entries))
+	return nil
+}
+
+func (mv *MatcherView) updateCallback(
+	ctx goka.UpdateContext, s storage.Storage, key string, value []byte,
+) error {
+	if err := goka.DefaultUpdate(ctx, s, key, value); err != nil {
+		return err
+	}
+	select {
+	case mv.updates <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -3,40 +3,95 @@ package collector
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/lovoo/goka"
 	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 const (
-	Group       goka.Group  = "collector-group"
-	inputStream goka.Stream = "filtered_messages"
+	Group         goka.Group  = "collector-group"
+	inputStream   goka.Stream = "filtered_messages"
+	CommandStream goka.Stream = "inbox_commands"
+	UnreadStream  goka.Stream = "unread_counts"
+	ArchiveStream goka.Stream = "archived_messages"
 )
 
-func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+type options struct {
+	retention retention
+	archive   bool
+}
+
+type Option func(*options)
+
+// WithMaxMessages keeps at most n newest messages per inbox.
+func WithMaxMessages(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.retention.maxMessages = n
+		}
+	}
+}
+
+// WithMaxAge evicts messages delivered more than d ago.
+func WithMaxAge(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.retention.maxAge = d
+		}
+	}
+}
+
+// WithArchive emits evicted messages to ArchiveStream instead of dropping
+// them.
+func WithArchive() Option {
+	return func(o *options) {
+		o.archive = true
+	}
+}
+
+func Run(
+	ctx context.Context, logger logger.Logger, brokers []string, opts ...Option,
+) error {
 	const op = "collector.Run"
 
-	g := makeGroupGraph(logger)
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	g := makeGroupGraph(logger, o)
 
 	p, err := goka.NewProcessor(brokers, g)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	go metrics.ObserveState(
+		ctx, string(Group), p.StateReader().ObserveStateChange())
+
 	return p.Run(ctx)
 }
 
-func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+func makeGroupGraph(logger logger.Logger, o options) *goka.GroupGraph {
 	msgCodec := messaging.NewMessageCodec(logger)
-	return goka.DefineGroup(
-		Group,
+	edges := []goka.Edge{
 		goka.Input(inputStream, msgCodec, inputCallback(logger)),
-		goka.Loop(msgCodec, loopCallback(logger)),
+		goka.Input(CommandStream, NewCommandCodec(logger),
+			commandCallback(logger)),
+		goka.Loop(newLoopCodec(logger), loopCallback(logger, o)),
+		goka.Output(UnreadStream, NewUnreadCountsCodec(logger)),
 		goka.Persist(messaging.NewMessageListCodec(logger)),
-	)
+	}
+	if o.archive {
+		edges = append(edges, goka.Output(ArchiveStream, msgCodec))
+	}
+	return goka.DefineGroup(Group, edges...)
 }
 
+// inputCallback never delivers shadowed messages to the recipient.
 func inputCallback(logger logger.Logger) goka.ProcessCallback {
 	const op = "collector.inputCallback"
 	log := logger.WithOp(op)
@@ -47,32 +102,126 @@ func inputCallback(logger logger.Logger) goka.ProcessCallback {
 			log.Error().Type("msgType", msg).Msg("invalid msg type")
 			return
 		}
+		if msgt.Shadowed {
+			return
+		}
 		ctx.Loopback(msgt.To, msg)
 	}
 }
 
-func loopCallback(logger logger.Logger) goka.ProcessCallback {
-	const op = "collector.loopCallback"
+// commandCallback passes commands through the loop, so they are applied
+// in order with the messages delivered to the same inbox.
+func commandCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "collector.commandCallback"
 	log := logger.WithOp(op)
 
 	return func(ctx goka.Context, msg any) {
-		msgt, ok := msg.(messaging.Message)
-		if !ok {
-			log.Error().Type("msgType", msg).Msg("invalid msg type")
+		cmd, ok := msg.(Command)
+		if !ok || !cmd.valid() {
+			log.Error().Interface("cmd", msg).Msg("invalid command")
 			return
 		}
+		ctx.Loopback(ctx.Key(), cmd)
+	}
+}
+
+// loopCallback takes delivery, read and eviction times from the record
+// timestamps, so that reprocessing the loop topic gives the same inbox.
+func loopCallback(logger logger.Logger, o options) goka.ProcessCallback {
+	const op = "collector.loopCallback"
+	log := logger.WithOp(op)
 
+	return func(ctx goka.Context, msg any) {
 		var ml []messaging.Message
 		if v := ctx.Value(); v != nil {
 			vml, ok := v.([]messaging.Message)
 			if !ok {
-				log.Error().Type("msgListType", msg).Msg("invalid msg list type")
+				log.Error().Type("msgListType", v).Msg("invalid msg list type")
 				return
 			}
 			ml = vml
 		}
+		messaging.Backfill(ctx.Key(), ml)
 
-		ml = append(ml, msgt)
+		switch msgt := msg.(type) {
+		case messaging.Message:
+			msgt.Seq = ctx.Offset() + 1
+			msgt.DeliveredAt = ctx.Timestamp().UTC()
+			var touched map[string]bool
+			ml, touched = evict(ctx, append(ml, msgt), o)
+			touched[msgt.From] = true
+			ctx.SetValue(ml)
+			emitUnread(ctx, ml, touched)
+			metrics.InboxSize.Observe(float64(len(ml)))
+		case Command:
+			applyCommand(ctx, ml, msgt)
+		default:
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+		}
+	}
+}
+
+// applyCommand updates the inbox and, when it changed, emits the unread
+// counts of the peers whose messages were affected.
+func applyCommand(ctx goka.Context, ml []messaging.Message, cmd Command) {
+	ml, touched := cmd.apply(ml, ctx.Timestamp().UTC())
+	if len(touched) == 0 {
+		return
+	}
+
+	if len(ml) == 0 {
+		ctx.Delete()
+	} else {
 		ctx.SetValue(ml)
 	}
+	emitUnread(ctx, ml, touched)
+}
+
+// evict trims the inbox by the retention policy, archiving the evicted
+// messages when enabled, and returns the messages that are kept and the
+// peers whose unread messages were evicted.
+func evict(
+	ctx goka.Context, ml []messaging.Message, o options,
+) ([]messaging.Message, map[string]bool) {
+	touched := make(map[string]bool)
+	kept, evicted := o.retention.trim(ml, ctx.Timestamp())
+	if len(evicted) == 0 {
+		return ml, touched
+	}
+
+	for _, m := range evicted {
+		if o.archive {
+			ctx.Emit(ArchiveStream, ctx.Key(), m)
+		}
+		if m.ReadAt.IsZero() {
+			touched[m.From] = true
+		}
+	}
+	metrics.MessagesEvicted.Add(float64(len(evicted)))
+	return kept, touched
+}
+
+// emitUnread emits the unread counts of the touched peers as they are in
+// ml. The collector is the only owner of the counts, so they are absolute
+// and the inbox processor applies them as they come.
+func emitUnread(
+	ctx goka.Context, ml []messaging.Message, touched map[string]bool,
+) {
+	ctx.Emit(UnreadStream, ctx.Key(), unreadCounts(ml, touched))
+}
+
+// unreadCounts counts the unread messages of the touched peers in ml.
+func unreadCounts(
+	ml []messaging.Message, touched map[string]bool,
+) UnreadCounts {
+	counts := make(UnreadCounts, len(touched))
+	for peer := range touched {
+		counts[peer] = 0
+	}
+	for _, m := range ml {
+		if touched[m.From] && m.ReadAt.IsZero() {
+			counts[m.From]++
+		}
+	}
+	return counts
 }
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	ActionRead   = "read"
+	ActionDelete = "delete"
+)
+
+// Command changes the inbox of the user it is keyed by. Read marks every
+// message up to and including MessageID as read, Delete drops MessageID.
+type Command struct {
+	Action    string
+	MessageID string
+}
+
+func (c Command) valid() bool {
+	switch c.Action {
+	case ActionRead, ActionDelete:
+		return c.MessageID != ""
+	}
+	return false
+}
+
+// apply returns the inbox after the command and the peers whose messages
+// it changed. Commands for unknown IDs change nothing.
+func (c Command) apply(
+	ml []messaging.Message, at time.Time,
+) ([]messaging.Message, map[string]bool) {
+	touched := make(map[string]bool)
+	idx := slices.IndexFunc(ml, func(m messaging.Message) bool {
+		return m.ID == c.MessageID
+	})
+	if idx < 0 {
+		return ml, touched
+	}
+
+	switch c.Action {
+	case ActionRead:
+		for i := range ml[:idx+1] {
+			if ml[i].ReadAt.IsZero() {
+				ml[i].ReadAt = at
+				touched[ml[i].From] = true
+			}
+		}
+	case ActionDelete:
+		touched[ml[idx].From] = true
+		ml = slices.Delete(ml, idx, idx+1)
+	}
+	return ml, touched
+}
+
+type CommandCodec struct {
+	log logger.Logger
+}
+
+func NewCommandCodec(log logger.Logger) CommandCodec {
+	return CommandCodec{log}
+}
+
+func (c CommandCodec) Encode(value any) ([]byte, error) {
+	const op = "CommandCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(Command); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal command")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c CommandCodec) Decode(data []byte) (any, error) {
+	const op = "CommandCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal command")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return cmd, nil
+}
+
+// loopCodec carries both messages and commands through the loop topic.
+// Messages keep the plain message encoding, so records looped before
+// commands existed still decode.
+type loopCodec struct {
+	log logger.Logger
+	msg messaging.MessageCodec
+	cmd CommandCodec
+}
+
+type loopCommand struct {
+	Command *Command
+}
+
+func newLoopCodec(log logger.Logger) loopCodec {
+	return loopCodec{log, messaging.NewMessageCodec(log), NewCommandCodec(log)}
+}
+
+func (c loopCodec) Encode(value any) ([]byte, error) {
+	const op = "loopCodec.Encode"
+	log := c.log.WithOp(op)
+
+	cmd, ok := value.(Command)
+	if !ok {
+		return c.msg.Encode(value)
+	}
+
+	b, err := json.Marshal(loopCommand{&cmd})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal command")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c loopCodec) Decode(data []byte) (any, error) {
+	const op = "loopCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var lc loopCommand
+	if err := json.Unmarshal(data, &lc); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal loop value")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if lc.Command != nil {
+		return *lc.Command, nil
+	}
+	return c.msg.Decode(data)
+}
+
+// UnreadCounts maps a peer name to the number of unread messages the user
+// has from them.
+type UnreadCounts map[string]int
+
+type UnreadCountsCodec struct {
+	log logger.Logger
+}
+
+func NewUnreadCountsCodec(log logger.Logger) UnreadCountsCodec {
+	return UnreadCountsCodec{log}
+}
+
+func (c UnreadCountsCodec) Encode(value any) ([]byte, error) {
+	const op = "UnreadCountsCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(UnreadCounts); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal unread counts")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c UnreadCountsCodec) Decode(data []byte) (any, error) {
+	const op = "UnreadCountsCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var uc UnreadCounts
+	if err := json.Unmarshal(data, &uc); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal unread counts")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return uc, nil
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+func TestCommandApply(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	earlier := at.Add(-time.Hour)
+
+	inbox := func() []messaging.Message {
+		return []messaging.Message{
+			{ID: "1", From: "david"},
+			{ID: "2", From: "sam", ReadAt: earlier},
+			{ID: "3", From: "david"},
+			{ID: "4", From: "sam"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		cmd         Command
+		wantIDs     []string
+		wantRead    []string
+		wantTouched map[string]bool
+	}{
+		{
+			name:        "read marks everything up to the id",
+			cmd:         Command{Action: ActionRead, MessageID: "3"},
+			wantIDs:     []string{"1", "2", "3", "4"},
+			wantRead:    []string{"1", "2", "3"},
+			wantTouched: map[string]bool{"david": true},
+		},
+		{
+			name:        "read of an already read message",
+			cmd:         Command{Action: ActionRead, MessageID: "2"},
+			wantIDs:     []string{"1", "2", "3", "4"},
+			wantRead:    []string{"1", "2"},
+			wantTouched: map[string]bool{"david": true},
+		},
+		{
+			name:        "read all",
+			cmd:         Command{Action: ActionRead, MessageID: "4"},
+			wantIDs:     []string{"1", "2", "3", "4"},
+			wantRead:    []string{"1", "2", "3", "4"},
+			wantTouched: map[string]bool{"david": true, "sam": true},
+		},
+		{
+			name:        "delete",
+			cmd:         Command{Action: ActionDelete, MessageID: "3"},
+			wantIDs:     []string{"1", "2", "4"},
+			wantRead:    []string{"2"},
+			wantTouched: map[string]bool{"david": true},
+		},
+		{
+			name:        "unknown id",
+			cmd:         Command{Action: ActionDelete, MessageID: "9"},
+			wantIDs:     []string{"1", "2", "3", "4"},
+			wantRead:    []string{"2"},
+			wantTouched: map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ml, touched := tt.cmd.apply(inbox(), at)
+
+			var ids, read []string
+			for _, m := range ml {
+				ids = append(ids, m.ID)
+				if !m.ReadAt.IsZero() {
+					read = append(read, m.ID)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(read, tt.wantRead) {
+				t.Errorf("read = %v, want %v", read, tt.wantRead)
+			}
+			if !maps.Equal(touched, tt.wantTouched) {
+				t.Errorf("touched = %v, want %v", touched, tt.wantTouched)
+			}
+		})
+	}
+}
+
+func TestUnreadCounts(t *testing.T) {
+	ml := []messaging.Message{
+		{From: "david"},
+		{From: "sam", ReadAt: time.Now()},
+		{From: "david"},
+		{From: "jack"},
+	}
+
+	got := unreadCounts(ml, map[string]bool{"david": true, "sam": true})
+	want := UnreadCounts{"david": 2, "sam": 0}
+	if !maps.Equal(got, want) {
+		t.Fatalf("counts = %v, want %v", got, want)
+	}
+}
+
+func TestLoopCodec(t *testing.T) {
+	c := newLoopCodec(logger.New("error"))
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"message", messaging.Message{ID: "1", From: "david", To: "jack"}},
+		{"command", Command{Action: ActionRead, MessageID: "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := c.Encode(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Fatalf("decoded = %#v, want %#v", got, tt.value)
+			}
+		})
+	}
+
+	t.Run("legacy message", func(t *testing.T) {
+		got, err := c.Decode([]byte(`{"From":"david","To":"jack"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := messaging.Message{From: "david", To: "jack"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("decoded = %#v, want %#v", got, want)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"slices"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+// retention limits an inbox by message count and age. Zero values disable
+// the corresponding limit.
+type retention struct {
+	maxMessages int
+	maxAge      time.Duration
+}
+
+// trim splits ml, which is ordered by delivery, into the messages to keep
+// and the evicted oldest ones. Messages stored before DeliveredAt existed
+// fall back to CreatedAt, and messages with neither have an unknown age
+// and are only evicted by the count limit.
+func (r retention) trim(
+	ml []messaging.Message, now time.Time,
+) (kept, evicted []messaging.Message) {
+	start := 0
+	if r.maxMessages > 0 && len(ml) > r.maxMessages {
+		start = len(ml) - r.maxMessages
+	}
+	if r.maxAge <= 0 {
+		return ml[start:], ml[:start]
+	}
+
+	cutoff := now.Add(-r.maxAge)
+	evicted = slices.Clone(ml[:start])
+	i := start
+	for ; i < len(ml); i++ {
+		at := deliveredAt(ml[i])
+		if at.IsZero() {
+			kept = append(kept, ml[i])
+			continue
+		}
+		if !at.Before(cutoff) {
+			break
+		}
+		evicted = append(evicted, ml[i])
+	}
+	if len(evicted) == start {
+		return ml[start:], ml[:start]
+	}
+	return append(kept, ml[i:]...), evicted
+}
+
+func deliveredAt(m messaging.Message) time.Time {
+	if !m.DeliveredAt.IsZero() {
+		return m.DeliveredAt
+	}
+	return m.CreatedAt
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+func TestRetentionTrim(t *testing.T) {
+	now := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	delivered := func(id string, age time.Duration) messaging.Message {
+		return messaging.Message{ID: id, DeliveredAt: now.Add(-age)}
+	}
+	created := func(id string, age time.Duration) messaging.Message {
+		return messaging.Message{ID: id, CreatedAt: now.Add(-age)}
+	}
+	unknown := func(id string) messaging.Message {
+		return messaging.Message{ID: id}
+	}
+
+	tests := []struct {
+		name        string
+		r           retention
+		ml          []messaging.Message
+		wantKept    []string
+		wantEvicted []string
+	}{
+		{
+			name:     "no limits",
+			ml:       []messaging.Message{delivered("a", 90*day), unknown("b")},
+			wantKept: []string{"a", "b"},
+		},
+		{
+			name: "count limit drops the oldest",
+			r:    retention{maxMessages: 2},
+			ml: []messaging.Message{
+				unknown("a"), delivered("b", day), delivered("c", 0),
+			},
+			wantKept:    []string{"b", "c"},
+			wantEvicted: []string{"a"},
+		},
+		{
+			name: "age limit",
+			r:    retention{maxAge: 30 * day},
+			ml: []messaging.Message{
+				delivered("a", 40*day), delivered("b", 31*day),
+				delivered("c", 29*day), delivered("d", 0),
+			},
+			wantKept:    []string{"c", "d"},
+			wantEvicted: []string{"a", "b"},
+		},
+		{
+			name: "unknown age is kept",
+			r:    retention{maxAge: 30 * day},
+			ml: []messaging.Message{
+				unknown("a"), unknown("b"), delivered("c", 0),
+			},
+			wantKept: []string{"a", "b", "c"},
+		},
+		{
+			name: "unknown age does not stop age eviction",
+			r:    retention{maxAge: 30 * day},
+			ml: []messaging.Message{
+				unknown("a"), delivered("b", 40*day), delivered("c", 0),
+			},
+			wantKept:    []string{"a", "c"},
+			wantEvicted: []string{"b"},
+		},
+		{
+			name: "creation time stands in for delivery time",
+			r:    retention{maxAge: 30 * day},
+			ml: []messaging.Message{
+				created("a", 40*day), created("b", day),
+			},
+			wantKept:    []string{"b"},
+			wantEvicted: []string{"a"},
+		},
+		{
+			name: "both limits",
+			r:    retention{maxMessages: 3, maxAge: 30 * day},
+			ml: []messaging.Message{
+				delivered("a", 50*day), unknown("b"),
+				delivered("c", 40*day), delivered("d", 0),
+			},
+			wantKept:    []string{"b", "d"},
+			wantEvicted: []string{"a", "c"},
+		},
+	}
+
+	ids := func(ml []messaging.Message) []string {
+		var ids []string
+		for _, m := range ml {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ml := slices.Clone(tt.ml)
+			kept, evicted := tt.r.trim(ml, now)
+			if got := ids(kept); !slices.Equal(got, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", got, tt.wantKept)
+			}
+			if got := ids(evicted); !slices.Equal(got, tt.wantEvicted) {
+				t.Errorf("evicted = %v, want %v", got, tt.wantEvicted)
+			}
+		})
+	}
+}
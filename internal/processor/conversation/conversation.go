@@ -0,0 +1,85 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	Group       goka.Group  = "conversation-group"
+	inputStream goka.Stream = "filtered_messages"
+)
+
+func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+	const op = "conversation.Run"
+
+	g := makeGroupGraph(logger)
+
+	p, err := goka.NewProcessor(brokers, g)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go metrics.ObserveState(
+		ctx, string(Group), p.StateReader().ObserveStateChange())
+
+	return p.Run(ctx)
+}
+
+func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+	msgCodec := messaging.NewMessageCodec(logger)
+	return goka.DefineGroup(
+		Group,
+		goka.Input(inputStream, msgCodec, inputCallback(logger)),
+		goka.Loop(msgCodec, loopCallback(logger)),
+		goka.Persist(messaging.NewMessageListCodec(logger)),
+	)
+}
+
+// inputCallback routes both directions of a conversation to the same key,
+// so the loop keeps a single ordered thread per pair of users.
+func inputCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "conversation.inputCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		msgt, ok := msg.(messaging.Message)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+		ctx.Loopback(messaging.ConversationKey(msgt.From, msgt.To), msg)
+	}
+}
+
+func loopCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "conversation.loopCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		msgt, ok := msg.(messaging.Message)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+
+		var ml []messaging.Message
+		if v := ctx.Value(); v != nil {
+			vml, ok := v.([]messaging.Message)
+			if !ok {
+				log.Error().Type("msgListType", v).Msg("invalid msg list type")
+				return
+			}
+			ml = vml
+		}
+
+		msgt.Seq = ctx.Offset() + 1
+		ml = append(ml, msgt)
+		ctx.SetValue(ml)
+	}
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+// Seen describes the first message accepted with an idempotency key, so
+// that the server can answer retries with the original result.
+type Seen struct {
+	At        time.Time
+	ExpiresAt time.Time
+	ID        string
+	CreatedAt time.Time
+	Digest    string
+}
+
+// Active reports whether the entry is still within its dedup window.
+func (s Seen) Active(t time.Time) bool {
+	return t.Before(s.ExpiresAt)
+}
+
+// Matches reports whether m repeats the first message.
+func (s Seen) Matches(m messaging.Message) bool {
+	return s.Digest == m.Digest()
+}
+
+// SeenKeys maps the idempotency keys a sender used within the dedup window
+// to the first message seen with them.
+type SeenKeys map[string]Seen
+
+func (s SeenKeys) prune(now time.Time, window time.Duration) bool {
+	pruned := false
+	for key, seen := range s {
+		if now.Sub(seen.At) > window {
+			delete(s, key)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// isDuplicate reports whether the idempotency key of m was already used
+// within the window and returns the first message seen with it, or
+// records m otherwise. Messages without a key are never duplicates, but
+// every message of the sender prunes the expired keys.
+func isDuplicate(
+	ctx goka.Context, m messaging.Message, window time.Duration,
+) (Seen, bool) {
+	seen, _ := ctx.Value().(SeenKeys)
+
+	now := ctx.Timestamp()
+	changed := seen.prune(now, window)
+
+	first, dup := seen[m.IdempotencyKey]
+	if m.IdempotencyKey != "" && !dup {
+		if seen == nil {
+			seen = make(SeenKeys)
+		}
+		seen[m.IdempotencyKey] = Seen{
+			At:        now,
+			ExpiresAt: now.Add(window),
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			Digest:    m.Digest(),
+		}
+		changed = true
+	}
+
+	switch {
+	case !changed:
+	case len(seen) == 0:
+		ctx.Delete()
+	default:
+		ctx.SetValue(seen)
+	}
+	return first, dup
+}
+
+type SeenKeysCodec struct {
+	log logger.Logger
+}
+
+func NewSeenKeysCodec(log logger.Logger) SeenKeysCodec {
+	return SeenKeysCodec{log}
+}
+
+func (c SeenKeysCodec) Encode(value any) ([]byte, error) {
+	const op = "SeenKeysCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(SeenKeys); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal seen keys")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c SeenKeysCodec) Decode(data []byte) (any, error) {
+	const op = "SeenKeysCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var s SeenKeys
+	if err := json.Unmarshal(data, &s); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal seen keys")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
+}
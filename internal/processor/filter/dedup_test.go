@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+func TestSeenMatches(t *testing.T) {
+	m := messaging.Message{To: "jack", Content: "hi"}
+	seen := Seen{Digest: m.Digest()}
+
+	tests := []struct {
+		name string
+		m    messaging.Message
+		want bool
+	}{
+		{"same message", m, true},
+		{"other content", messaging.Message{To: "jack", Content: "bye"}, false},
+		{"other recipient", messaging.Message{To: "sam", Content: "hi"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := seen.Matches(tt.m); got != tt.want {
+				t.Fatalf("Matches = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// gokaContext lets tableContext embed goka.Context, whose Context method
+// would otherwise clash with the embedded field name.
+type gokaContext = goka.Context
+
+type tableContext struct {
+	gokaContext
+	now   time.Time
+	value any
+}
+
+func (ctx *tableContext) Timestamp() time.Time { return ctx.now }
+
+func (ctx *tableContext) Value() any { return ctx.value }
+
+func (ctx *tableContext) SetValue(value any, _ ...goka.ContextOption) {
+	ctx.value = value
+}
+
+func (ctx *tableContext) Delete(_ ...goka.ContextOption) { ctx.value = nil }
+
+func TestIsDuplicate(t *testing.T) {
+	const window = 10 * time.Minute
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := &tableContext{now: start}
+
+	keyed := func(id, key, content string) messaging.Message {
+		return messaging.Message{
+			ID: id, IdempotencyKey: key, To: "jack", Content: content,
+		}
+	}
+
+	if _, dup := isDuplicate(ctx, keyed("1", "k1", "hi"), window); dup {
+		t.Fatal("first message is a duplicate")
+	}
+
+	ctx.now = start.Add(time.Minute)
+	first, dup := isDuplicate(ctx, keyed("2", "k1", "hi"), window)
+	if !dup || first.ID != "1" || !first.Matches(keyed("", "", "hi")) {
+		t.Fatalf("retry: dup = %t, first = %+v", dup, first)
+	}
+	if _, dup := isDuplicate(ctx, keyed("3", "", "hi"), window); dup {
+		t.Fatal("message without a key is a duplicate")
+	}
+
+	ctx.now = start.Add(window + time.Minute)
+	if _, dup := isDuplicate(ctx, keyed("4", "", "hi"), window); dup {
+		t.Fatal("message without a key is a duplicate")
+	}
+	if ctx.value != nil {
+		t.Fatalf("expired keys were not pruned: %+v", ctx.value)
+	}
+
+	if _, dup := isDuplicate(ctx, keyed("5", "k1", "bye"), window); dup {
+		t.Fatal("expired key is a duplicate")
+	}
+}
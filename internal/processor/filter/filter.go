@@ -3,51 +3,108 @@ package filter
 import (
 	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/lovoo/goka"
 	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
 	"github.com/niksmo/messaging/internal/processor/blocker"
 	"github.com/niksmo/messaging/internal/processor/censor"
+	"github.com/niksmo/messaging/internal/processor/userblock"
 	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
-	group        goka.Group  = "filter-group"
-	InputStream  goka.Stream = messaging.Stream
-	OutputStream goka.Stream = "filtered_messages"
+	group          goka.Group  = "filter-group"
+	InputStream    goka.Stream = messaging.Stream
+	OutputStream   goka.Stream = "filtered_messages"
+	RejectedStream goka.Stream = "rejected_messages"
 )
 
+const defaultDedupWindow = 10 * time.Minute
+
 var (
-	BlockerTable = blocker.Table
-	CensorTable  = censor.Table
+	BlockerTable   = blocker.Table
+	CensorTable    = censor.Table
+	UserBlockTable = userblock.Table
+	DedupTable     = goka.GroupTable(group)
 )
 
-func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+type options struct {
+	dedupWindow time.Duration
+}
+
+type Option func(*options)
+
+// WithDedupWindow sets how long message IDs are remembered to drop
+// retried messages.
+func WithDedupWindow(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.dedupWindow = d
+		}
+	}
+}
+
+func Run(
+	ctx context.Context, logger logger.Logger, brokers []string, opts ...Option,
+) error {
 	const op = "filter.Run"
 
-	g := makeGroupGraph(logger)
+	o := options{dedupWindow: defaultDedupWindow}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mv, err := censor.NewMatcherView(logger, brokers)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	g := makeGroupGraph(logger, o, mv)
 
 	p, err := goka.NewProcessor(brokers, g)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return p.Run(ctx)
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+
+	// Messages are not processed until the censor table is loaded, so
+	// that none of them passes uncensored.
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error { return mv.Run(ctx) })
+	eg.Go(func() error {
+		select {
+		case <-mv.Ready():
+		case <-ctx.Done():
+			return nil
+		}
+		return p.Run(ctx)
+	})
+	return eg.Wait()
 }
 
-func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+func makeGroupGraph(
+	logger logger.Logger, o options, mv *censor.MatcherView,
+) *goka.GroupGraph {
 	msgCodec := messaging.NewMessageCodec(logger)
 	return goka.DefineGroup(
 		group,
-		goka.Input(InputStream, msgCodec, processCallback(logger)),
+		goka.Input(InputStream, msgCodec, processCallback(logger, o, mv)),
 		goka.Output(OutputStream, msgCodec),
+		goka.Output(RejectedStream, messaging.NewRejectionCodec(logger)),
 		goka.Join(BlockerTable, blocker.NewBlockValueCodec(logger)),
-		goka.Lookup(CensorTable, censor.NewCensorValueCodec(logger)),
+		goka.Lookup(UserBlockTable, userblock.NewBlockListCodec(logger)),
+		goka.Persist(NewSeenKeysCodec(logger)),
 	)
 }
 
-func processCallback(logger logger.Logger) goka.ProcessCallback {
+func processCallback(
+	logger logger.Logger, o options, mv *censor.MatcherView,
+) goka.ProcessCallback {
 	const op = "filter.processCallback"
 	log := logger.WithOp(op)
 
@@ -61,14 +118,40 @@ func processCallback(logger logger.Logger) goka.ProcessCallback {
 		}
 
 		log.Info().Msg("receive message")
+		metrics.MessagesReceived.Inc()
+
+		if first, dup := isDuplicate(ctx, m, o.dedupWindow); dup {
+			log.Info().Str("id", m.ID).Str(
+				"reason", "duplicate message").Msg("skipped")
+			metrics.MessagesDuplicate.Inc()
+			if !first.Matches(m) {
+				reject(ctx, m, messaging.RejectReasonKeyConflict)
+			}
+			return
+		}
 
-		if senderBlocked(ctx) {
+		switch senderState(ctx) {
+		case blocker.StateBlocked:
 			log.Info().Str("reason", "user is blocked").Msg("skipped")
+			metrics.MessagesBlocked.Inc()
+			reject(ctx, m, messaging.RejectReasonSenderBlocked)
 			return
+		case blocker.StateShadowBanned:
+			log.Info().Msg("shadowed")
+			metrics.MessagesShadowed.Inc()
+			m.Shadowed = true
 		}
 
-		if applyCensor(ctx, &m) {
+		if blockedByRecipient(ctx, m) {
+			log.Info().Str("recipient", m.To).Str(
+				"reason", "blocked by recipient").Msg("skipped")
+			metrics.MessagesBlockedByRecipient.Inc()
+			return
+		}
+
+		if applyCensor(mv.Matcher(), &m) {
 			log.Info().Msg("censored")
+			metrics.MessagesCensored.Inc()
 		}
 
 		ctx.Emit(OutputStream, m.From, m)
@@ -77,20 +160,40 @@ func processCallback(logger logger.Logger) goka.ProcessCallback {
 	}
 }
 
-func senderBlocked(ctx goka.Context) bool {
+// reject tells the sender that m was not delivered.
+func reject(ctx goka.Context, m messaging.Message, reason string) {
+	ctx.Emit(RejectedStream, m.From, messaging.Rejection{
+		MessageID:  m.ID,
+		To:         m.To,
+		Reason:     reason,
+		RejectedAt: ctx.Timestamp().UTC(),
+	})
+}
+
+func senderState(ctx goka.Context) blocker.State {
 	v, ok := ctx.Join(BlockerTable).(blocker.BlockValue)
-	return ok && bool(v)
+	if !ok {
+		return blocker.StateActive
+	}
+	return v.StateAt(ctx.Timestamp())
 }
 
-func applyCensor(ctx goka.Context, msg *messaging.Message) (apply bool) {
-	s := strings.Fields(msg.Content)
-	for i, word := range s {
-		replacement := ctx.Lookup(CensorTable, word)
-		if replacement != nil {
-			s[i] = replacement.(string)
-			apply = true
-		}
+// blockedByRecipient reports whether the recipient blocked the sender.
+func blockedByRecipient(ctx goka.Context, m messaging.Message) bool {
+	bl, ok := ctx.Lookup(UserBlockTable, m.To).(userblock.BlockList)
+	if !ok {
+		return false
+	}
+	_, blocked := bl[m.From]
+	return blocked
+}
+
+// applyCensor replaces the censored words and phrases of the message,
+// keeping the spacing and punctuation around them.
+func applyCensor(m *censor.Matcher, msg *messaging.Message) bool {
+	content, ok := m.Replace(msg.Content)
+	if ok {
+		msg.Content = content
 	}
-	msg.Content = strings.Join(s, " ")
-	return
+	return ok
 }
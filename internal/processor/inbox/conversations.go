@@ -0,0 +1,93 @@
+package inbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+type Conversation struct {
+	Peer        string
+	LastMessage messaging.Message
+	UpdatedAt   time.Time
+	Unread      int
+}
+
+// Conversations maps a peer name to the user's conversation with them.
+type Conversations map[string]Conversation
+
+// update records m as the last message of the conversation it belongs to.
+// Unread counts come from the collector, which owns them.
+func (cs Conversations) update(
+	owner string, m messaging.Message, at time.Time,
+) {
+	peer := m.To
+	if m.To == owner {
+		peer = m.From
+	}
+
+	c := cs[peer]
+	c.Peer = peer
+	c.LastMessage = m
+	c.UpdatedAt = at
+	cs[peer] = c
+}
+
+// setUnread overrides the unread counts of the conversations and reports
+// whether any of them changed. A count may arrive before the message it
+// includes, so a conversation is created for it without a last message.
+func (cs Conversations) setUnread(counts map[string]int) bool {
+	changed := false
+	for peer, n := range counts {
+		c, ok := cs[peer]
+		if ok && c.Unread == n || !ok && n == 0 {
+			continue
+		}
+		c.Peer = peer
+		c.Unread = n
+		cs[peer] = c
+		changed = true
+	}
+	return changed
+}
+
+type ConversationsCodec struct {
+	log logger.Logger
+}
+
+func NewConversationsCodec(log logger.Logger) ConversationsCodec {
+	return ConversationsCodec{log}
+}
+
+func (c ConversationsCodec) Encode(value any) ([]byte, error) {
+	const op = "ConversationsCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(Conversations); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal conversations")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c ConversationsCodec) Decode(data []byte) (any, error) {
+	const op = "ConversationsCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var cs Conversations
+	if err := json.Unmarshal(data, &cs); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal conversations")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return cs, nil
+}
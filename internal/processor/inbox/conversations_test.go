@@ -0,0 +1,59 @@
+package inbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+func TestConversationsUnreadBeforeMessage(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := messaging.Message{From: "david", To: "jack", Content: "hi"}
+
+	tests := []struct {
+		name  string
+		apply func(cs Conversations)
+	}{
+		{
+			name: "message first",
+			apply: func(cs Conversations) {
+				cs.update("jack", m, at)
+				cs.setUnread(map[string]int{"david": 1})
+			},
+		},
+		{
+			name: "count first",
+			apply: func(cs Conversations) {
+				cs.setUnread(map[string]int{"david": 1})
+				cs.update("jack", m, at)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := make(Conversations)
+			tt.apply(cs)
+
+			c := cs["david"]
+			if c.Unread != 1 || c.LastMessage != m || !c.UpdatedAt.Equal(at) {
+				t.Fatalf("conversation = %+v", c)
+			}
+		})
+	}
+}
+
+func TestConversationsSetUnread(t *testing.T) {
+	cs := Conversations{"david": {Peer: "david", Unread: 2}}
+
+	if cs.setUnread(map[string]int{"david": 2, "sam": 0}) {
+		t.Error("unchanged counts reported a change")
+	}
+	if _, ok := cs["sam"]; ok {
+		t.Error("zero count created a conversation")
+	}
+	if !cs.setUnread(map[string]int{"david": 0}) || cs["david"].Unread != 0 {
+		t.Errorf("count not overridden: %+v", cs["david"])
+	}
+}
@@ -0,0 +1,125 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/internal/processor/collector"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	group       goka.Group  = "inbox-group"
+	inputStream goka.Stream = "filtered_messages"
+)
+
+var Table goka.Table = goka.GroupTable(group)
+
+func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+	const op = "inbox.Run"
+
+	g := makeGroupGraph(logger)
+
+	p, err := goka.NewProcessor(brokers, g)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+
+	return p.Run(ctx)
+}
+
+func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+	msgCodec := messaging.NewMessageCodec(logger)
+	return goka.DefineGroup(
+		group,
+		goka.Input(inputStream, msgCodec, inputCallback(logger)),
+		goka.Input(collector.UnreadStream,
+			collector.NewUnreadCountsCodec(logger), unreadCallback(logger)),
+		goka.Loop(msgCodec, loopCallback(logger)),
+		goka.Persist(NewConversationsCodec(logger)),
+	)
+}
+
+// inputCallback updates the conversation lists of both participants, or
+// only the sender's for shadowed messages.
+func inputCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "inbox.inputCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		msgt, ok := msg.(messaging.Message)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+		ctx.Loopback(msgt.From, msg)
+		if !msgt.Shadowed {
+			ctx.Loopback(msgt.To, msg)
+		}
+	}
+}
+
+func loopCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "inbox.loopCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		msgt, ok := msg.(messaging.Message)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+
+		cs, ok := conversations(ctx)
+		if !ok {
+			log.Error().Type("valueType", ctx.Value()).Msg("invalid value type")
+			return
+		}
+
+		cs.update(ctx.Key(), msgt, ctx.Timestamp())
+		ctx.SetValue(cs)
+	}
+}
+
+// unreadCallback applies the unread counts the collector recomputes after
+// messages were delivered, read, deleted or evicted.
+func unreadCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "inbox.unreadCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		counts, ok := msg.(collector.UnreadCounts)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+
+		cs, ok := conversations(ctx)
+		if !ok {
+			log.Error().Type("valueType", ctx.Value()).Msg("invalid value type")
+			return
+		}
+
+		if cs.setUnread(counts) {
+			ctx.SetValue(cs)
+		}
+	}
+}
+
+func conversations(ctx goka.Context) (Conversations, bool) {
+	v := ctx.Value()
+	if v == nil {
+		return make(Conversations), true
+	}
+	cs, ok := v.(Conversations)
+	if ok && cs == nil {
+		cs = make(Conversations)
+	}
+	return cs, ok
+}
@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	group       goka.Group  = "outbox-group"
+	inputStream goka.Stream = "rejected_messages"
+
+	// maxRejections is how many of the latest rejections a sender keeps.
+	maxRejections = 100
+)
+
+var Table goka.Table = goka.GroupTable(group)
+
+func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+	const op = "outbox.Run"
+
+	g := makeGroupGraph(logger)
+
+	p, err := goka.NewProcessor(brokers, g)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+
+	return p.Run(ctx)
+}
+
+func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+	return goka.DefineGroup(
+		group,
+		goka.Input(inputStream, messaging.NewRejectionCodec(logger),
+			processCallback(logger)),
+		goka.Persist(messaging.NewRejectionListCodec(logger)),
+	)
+}
+
+// processCallback appends the rejection to the list of the sender it is
+// keyed by.
+func processCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "outbox.processCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		rej, ok := msg.(messaging.Rejection)
+		if !ok {
+			log.Error().Type("msgType", msg).Msg("invalid msg type")
+			return
+		}
+
+		rl, _ := ctx.Value().([]messaging.Rejection)
+		rl = append(rl, rej)
+		if len(rl) > maxRejections {
+			rl = rl[len(rl)-maxRejections:]
+		}
+		ctx.SetValue(rl)
+	}
+}
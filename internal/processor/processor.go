@@ -2,24 +2,84 @@ package processor
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"time"
 
+	"github.com/niksmo/messaging/internal/metrics"
 	"github.com/niksmo/messaging/internal/processor/blocker"
 	"github.com/niksmo/messaging/internal/processor/censor"
 	"github.com/niksmo/messaging/internal/processor/collector"
+	"github.com/niksmo/messaging/internal/processor/conversation"
 	"github.com/niksmo/messaging/internal/processor/filter"
+	"github.com/niksmo/messaging/internal/processor/inbox"
+	"github.com/niksmo/messaging/internal/processor/outbox"
+	"github.com/niksmo/messaging/internal/processor/userblock"
 	"github.com/niksmo/messaging/pkg/logger"
 	"github.com/niksmo/messaging/pkg/topicinit"
 	"golang.org/x/sync/errgroup"
 )
 
-func WithOptions(brokers []string, npart, rfactor int) *options {
-	return &options{brokers, npart, rfactor}
+const metricsShutdownTimeout = 5 * time.Second
+
+func WithOptions(
+	brokers []string, npart, rfactor int, opts ...Option,
+) *options {
+	o := &options{brokers: brokers, npart: npart, rfactor: rfactor}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
 type options struct {
-	brokers []string
-	npart   int
-	rfactor int
+	brokers     []string
+	npart       int
+	rfactor     int
+	metricsAddr string
+	dedupWindow time.Duration
+	maxMessages int
+	maxAge      time.Duration
+	archive     bool
+
+	blockCleanupInterval time.Duration
+}
+
+type Option func(*options)
+
+func WithDedupWindow(d time.Duration) Option {
+	return func(o *options) {
+		o.dedupWindow = d
+	}
+}
+
+// WithRetention limits every inbox to maxMessages messages delivered no
+// longer than maxAge ago. Zero values disable the corresponding limit.
+func WithRetention(maxMessages int, maxAge time.Duration) Option {
+	return func(o *options) {
+		o.maxMessages = maxMessages
+		o.maxAge = maxAge
+	}
+}
+
+// WithArchive archives the messages evicted by the retention policy.
+func WithArchive() Option {
+	return func(o *options) {
+		o.archive = true
+	}
+}
+
+// WithBlockCleanupInterval sets how often expired blocks are cleared.
+func WithBlockCleanupInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.blockCleanupInterval = d
+	}
+}
+
+func WithMetricsAddr(addr string) Option {
+	return func(o *options) {
+		o.metricsAddr = addr
+	}
 }
 
 type procRunner func(context.Context, logger.Logger, []string) error
@@ -32,7 +92,11 @@ func Run(ctx context.Context, log logger.Logger, opt *options) {
 
 	initTopics(log, opt.brokers, opt.npart, opt.rfactor)
 
-	runProcessors(ctx, g, log, opt.brokers)
+	runProcessors(ctx, g, log, opt)
+
+	if opt.metricsAddr != "" {
+		runMetricsServer(ctx, g, log, opt.metricsAddr)
+	}
 
 	log.Info().Msg("processors are running")
 
@@ -48,8 +112,13 @@ func initTopics(log logger.Logger, brokers []string, npart, rfactor int) {
 	topics := []string{
 		string(filter.InputStream),
 		string(filter.OutputStream),
+		string(filter.RejectedStream),
 		string(blocker.Stream),
 		string(censor.Stream),
+		string(userblock.Stream),
+		string(collector.CommandStream),
+		string(collector.UnreadStream),
+		string(collector.ArchiveStream),
 	}
 
 	for _, topic := range topics {
@@ -63,7 +132,10 @@ func initTopics(log logger.Logger, brokers []string, npart, rfactor int) {
 
 	tables := []string{
 		string(filter.BlockerTable),
+		string(blocker.AuditTable),
 		string(filter.CensorTable),
+		string(filter.UserBlockTable),
+		string(filter.DedupTable),
 	}
 	for _, table := range tables {
 		err := topicinit.EnsureTableExists(table, brokers, npart)
@@ -82,17 +154,68 @@ func runProcessors(
 	ctx context.Context,
 	g *errgroup.Group,
 	log logger.Logger,
-	brokers []string,
+	opt *options,
 ) {
 	procRunners := []procRunner{
-		blocker.Run,
+		func(ctx context.Context, log logger.Logger, brokers []string) error {
+			return blocker.Run(ctx, log, brokers,
+				blocker.WithCleanupInterval(opt.blockCleanupInterval))
+		},
 		censor.Run,
-		filter.Run,
-		collector.Run,
+		userblock.Run,
+		func(ctx context.Context, log logger.Logger, brokers []string) error {
+			return filter.Run(ctx, log, brokers,
+				filter.WithDedupWindow(opt.dedupWindow))
+		},
+		func(ctx context.Context, log logger.Logger, brokers []string) error {
+			return collector.Run(ctx, log, brokers, collectorOptions(opt)...)
+		},
+		conversation.Run,
+		inbox.Run,
+		outbox.Run,
 	}
 	for _, runner := range procRunners {
 		g.Go(func() error {
-			return runner(ctx, log, brokers)
+			return runner(ctx, log, opt.brokers)
 		})
 	}
 }
+
+func collectorOptions(opt *options) []collector.Option {
+	opts := []collector.Option{
+		collector.WithMaxMessages(opt.maxMessages),
+		collector.WithMaxAge(opt.maxAge),
+	}
+	if opt.archive {
+		opts = append(opts, collector.WithArchive())
+	}
+	return opts
+}
+
+func runMetricsServer(
+	ctx context.Context,
+	g *errgroup.Group,
+	log logger.Logger,
+	addr string,
+) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	s := &http.Server{Addr: addr, Handler: mux}
+
+	g.Go(func() error {
+		log.Info().Str("addr", addr).Msg("start metrics listener")
+		err := s.ListenAndServe()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	})
+
+	g.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(
+			context.Background(), metricsShutdownTimeout)
+		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	})
+}
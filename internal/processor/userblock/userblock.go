@@ -0,0 +1,160 @@
+package userblock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const (
+	group  goka.Group  = "userblock-group"
+	Stream goka.Stream = "user_blocks"
+)
+
+var Table goka.Table = goka.GroupTable(group)
+
+// Change blocks or unblocks Peer for the user the change is keyed by.
+type Change struct {
+	Peer    string
+	Blocked bool
+}
+
+// BlockList maps the peers a user blocked to the time they were blocked.
+type BlockList map[string]time.Time
+
+func Run(ctx context.Context, logger logger.Logger, brokers []string) error {
+	const op = "userblock.Run"
+
+	g := makeGroupGraph(logger)
+
+	p, err := goka.NewProcessor(brokers, g)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go metrics.ObserveState(
+		ctx, string(group), p.StateReader().ObserveStateChange())
+
+	return p.Run(ctx)
+}
+
+func makeGroupGraph(logger logger.Logger) *goka.GroupGraph {
+	return goka.DefineGroup(
+		group,
+		goka.Input(Stream, NewChangeCodec(logger), processCallback(logger)),
+		goka.Persist(NewBlockListCodec(logger)),
+	)
+}
+
+func processCallback(logger logger.Logger) goka.ProcessCallback {
+	const op = "userblock.processCallback"
+	log := logger.WithOp(op)
+
+	return func(ctx goka.Context, msg any) {
+		c, ok := msg.(Change)
+		if !ok || c.Peer == "" {
+			log.Error().Interface("msg", msg).Msg("invalid change")
+			return
+		}
+
+		bl, _ := ctx.Value().(BlockList)
+		if bl == nil {
+			bl = make(BlockList)
+		}
+
+		if c.Blocked {
+			if _, ok := bl[c.Peer]; ok {
+				return
+			}
+			bl[c.Peer] = ctx.Timestamp()
+		} else {
+			delete(bl, c.Peer)
+		}
+
+		if len(bl) == 0 {
+			ctx.Delete()
+			return
+		}
+		ctx.SetValue(bl)
+	}
+}
+
+type ChangeCodec struct {
+	log logger.Logger
+}
+
+func NewChangeCodec(log logger.Logger) ChangeCodec {
+	return ChangeCodec{log}
+}
+
+func (c ChangeCodec) Encode(value any) ([]byte, error) {
+	const op = "ChangeCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(Change); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal change")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c ChangeCodec) Decode(data []byte) (any, error) {
+	const op = "ChangeCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var ch Change
+	if err := json.Unmarshal(data, &ch); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal change")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ch, nil
+}
+
+type BlockListCodec struct {
+	log logger.Logger
+}
+
+func NewBlockListCodec(log logger.Logger) BlockListCodec {
+	return BlockListCodec{log}
+}
+
+func (c BlockListCodec) Encode(value any) ([]byte, error) {
+	const op = "BlockListCodec.Encode"
+	log := c.log.WithOp(op)
+
+	if _, ok := value.(BlockList); !ok {
+		log.Error().Msg("invalid value type")
+		return nil, fmt.Errorf("%s: %w", op, errors.New("invalid value type"))
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal block list")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return b, nil
+}
+
+func (c BlockListCodec) Decode(data []byte) (any, error) {
+	const op = "BlockListCodec.Decode"
+	log := c.log.WithOp(op)
+
+	var bl BlockList
+	if err := json.Unmarshal(data, &bl); err != nil {
+		log.Error().Err(err).Msg("failed to unmarshal block list")
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return bl, nil
+}
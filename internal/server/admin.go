@@ -0,0 +1,460 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/processor/blocker"
+	"github.com/niksmo/messaging/internal/processor/censor"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+const maxAdminBodySize = 4 << 10
+
+var (
+	errNotAdmin        = errors.New("admin token required")
+	errTableRecovering = errors.New("table view is recovering")
+)
+
+type syncEmitter interface {
+	EmitSync(key string, msg any) error
+}
+
+type tableView interface {
+	Get(key string) (any, error)
+	Iterator() (goka.Iterator, error)
+	Recovered() bool
+}
+
+type actorKey struct{}
+
+type blockRequest struct {
+	For    string `json:"for"`
+	Reason string `json:"reason"`
+	Shadow bool   `json:"shadow"`
+}
+
+type blockResponse struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Reason    string    `json:"reason,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	BlockedAt time.Time `json:"blocked_at,omitzero"`
+}
+
+type moderationEventResponse struct {
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+type historyResponse struct {
+	Name   string                    `json:"name"`
+	Count  int                       `json:"count"`
+	Events []moderationEventResponse `json:"events"`
+}
+
+type blockListResponse struct {
+	Count  int             `json:"count"`
+	Blocks []blockResponse `json:"blocks"`
+}
+
+type censorRequest struct {
+	Change string `json:"change"`
+}
+
+type censorResponse struct {
+	Word   string `json:"word"`
+	Change string `json:"change"`
+}
+
+type censorListResponse struct {
+	Count int              `json:"count"`
+	Words []censorResponse `json:"words"`
+}
+
+type adminHandler struct {
+	l          logger.Logger
+	a          authenticator
+	blockEmit  syncEmitter
+	blockView  tableView
+	auditView  tableView
+	censorEmit syncEmitter
+	censorView tableView
+}
+
+func NewAdminHandler(
+	l logger.Logger,
+	mux mux,
+	a authenticator,
+	blockEmit syncEmitter,
+	blockView tableView,
+	auditView tableView,
+	censorEmit syncEmitter,
+	censorView tableView,
+) {
+	h := &adminHandler{
+		l, a, blockEmit, blockView, auditView, censorEmit, censorView,
+	}
+	mux.HandleFunc("GET /admin/blocks", h.adminOnly(h.listBlocksHandler))
+	mux.HandleFunc("PUT /admin/blocks/{name}", h.adminOnly(h.blockHandler))
+	mux.HandleFunc("DELETE /admin/blocks/{name}",
+		h.adminOnly(h.unblockHandler))
+	mux.HandleFunc("GET /admin/blocks/{name}/history",
+		h.adminOnly(h.historyHandler))
+	mux.HandleFunc("GET /admin/censor", h.adminOnly(h.listCensorHandler))
+	mux.HandleFunc("PUT /admin/censor/{word}", h.adminOnly(h.censorHandler))
+	mux.HandleFunc("DELETE /admin/censor/{word}",
+		h.adminOnly(h.uncensorHandler))
+}
+
+func (h *adminHandler) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	const op = "adminHandler.adminOnly"
+	log := h.l.WithOp(op)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := verifyRequest(w, bearerToken(r), h.a, log)
+		if !ok {
+			return
+		}
+
+		if !claims.Admin {
+			log.Info().Str("subject", claims.Subject).Msg("not an admin")
+			http.Error(w, errNotAdmin.Error(), http.StatusForbidden)
+			return
+		}
+
+		log.Info().Str("admin", claims.Subject).Str(
+			"method", r.Method).Str("path", r.URL.Path).Send()
+		ctx := context.WithValue(r.Context(), actorKey{}, claims.Subject)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// blockHandler blocks permanently unless the optional body sets a
+// duration in "for". With "shadow" the user is shadow-banned instead.
+func (h *adminHandler) blockHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "adminHandler.blockHandler"
+	log := h.l.WithOp(op)
+
+	e, err := h.readBlock(w, r)
+	if err != nil {
+		log.Info().Err(err).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+		return
+	}
+
+	h.emit(w, op, h.blockEmit, r.PathValue("name"), e)
+}
+
+// unblockHandler accepts the same optional body as blockHandler, of which
+// only the reason is used.
+func (h *adminHandler) unblockHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "adminHandler.unblockHandler"
+	log := h.l.WithOp(op)
+
+	e, err := h.readBlock(w, r)
+	if err != nil {
+		log.Info().Err(err).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+		return
+	}
+	e.Action, e.ExpiresAt = blocker.ActionUnblock, time.Time{}
+
+	h.emit(w, op, h.blockEmit, r.PathValue("name"), e)
+}
+
+// readBlock returns a block event by the admin making the request.
+func (h *adminHandler) readBlock(
+	w http.ResponseWriter, r *http.Request,
+) (blocker.Event, error) {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	e := blocker.Event{
+		Action:    blocker.ActionBlock,
+		Actor:     actor,
+		Timestamp: time.Now().UTC(),
+	}
+
+	data, err := readBody(w, r, maxAdminBodySize)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return e, err
+	}
+
+	var req blockRequest
+	if err := decodeStrict(data, &req); err != nil {
+		return e, err
+	}
+
+	if req.For != "" {
+		d, err := time.ParseDuration(req.For)
+		if err != nil || d <= 0 {
+			verr := newValidationError("invalid block request")
+			verr.addField("for", "must be a positive duration")
+			return e, verr
+		}
+		e.ExpiresAt = e.Timestamp.Add(d)
+	}
+	if req.Shadow {
+		e.Action = blocker.ActionShadowBan
+	}
+	e.Reason = strings.TrimSpace(req.Reason)
+	return e, nil
+}
+
+func (h *adminHandler) historyHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "adminHandler.historyHandler"
+	log := h.l.WithOp(op)
+
+	name := r.PathValue("name")
+
+	hist, err := h.getHistory(name)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		h.writeViewError(w, err)
+		return
+	}
+
+	resp := historyResponse{
+		Name:   name,
+		Count:  len(hist),
+		Events: make([]moderationEventResponse, 0, len(hist)),
+	}
+	for _, e := range hist {
+		resp.Events = append(resp.Events, moderationEventResponse{
+			Action:    e.Action,
+			Actor:     e.Actor,
+			Reason:    e.Reason,
+			Timestamp: e.Timestamp,
+			ExpiresAt: e.ExpiresAt,
+		})
+	}
+
+	if err := writeJSON(w, http.StatusOK, resp); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (h *adminHandler) getHistory(name string) (blocker.History, error) {
+	if !h.auditView.Recovered() {
+		return nil, errTableRecovering
+	}
+
+	v, err := h.auditView.Get(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	hist, ok := v.(blocker.History)
+	if !ok {
+		return nil, fmt.Errorf("unexpected history type %T", v)
+	}
+	return hist, nil
+}
+
+func (h *adminHandler) censorHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "adminHandler.censorHandler"
+	log := h.l.WithOp(op)
+
+	change, err := h.readCensor(w, r)
+	if err != nil {
+		log.Info().Err(err).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+		return
+	}
+
+	h.emitCensor(w, op, r.PathValue("word"), change)
+}
+
+// readCensor returns the replacement requested for a censored word.
+func (h *adminHandler) readCensor(
+	w http.ResponseWriter, r *http.Request,
+) (string, error) {
+	data, err := readBody(w, r, maxAdminBodySize)
+	if err != nil {
+		return "", err
+	}
+
+	var req censorRequest
+	if err := decodeStrict(data, &req); err != nil {
+		return "", err
+	}
+
+	req.Change = strings.TrimSpace(req.Change)
+	if req.Change == "" {
+		verr := newValidationError("invalid censor word")
+		verr.addField("change", "required")
+		return "", verr
+	}
+	return req.Change, nil
+}
+
+// uncensorHandler emits an empty change, which the censor processor
+// treats as a deletion.
+func (h *adminHandler) uncensorHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "adminHandler.uncensorHandler"
+	h.emitCensor(w, op, r.PathValue("word"), "")
+}
+
+// emitCensor stores the change under the folded key of the word. Entries
+// stored before keys were folded, such as "Apple" for "apple", are
+// deleted first, so that they are re-keyed on change and can be deleted.
+func (h *adminHandler) emitCensor(
+	w http.ResponseWriter, op, word, change string,
+) {
+	log := h.l.WithOp(op)
+
+	key := censor.Key(word)
+	var legacy []string
+	err := h.iterate(h.censorView, func(k string, _ any) {
+		if k != key && censor.Key(k) == key {
+			legacy = append(legacy, k)
+		}
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate view")
+		h.writeViewError(w, err)
+		return
+	}
+
+	for _, k := range legacy {
+		if err := h.censorEmit.EmitSync(k, ""); err != nil {
+			log.Error().Err(err).Str("key", k).Msg("failed to emit")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Info().Str("key", k).Msg("legacy key deleted")
+	}
+
+	h.emit(w, op, h.censorEmit, key, change)
+}
+
+func (h *adminHandler) emit(
+	w http.ResponseWriter,
+	op string,
+	e syncEmitter,
+	key string,
+	value any,
+) {
+	log := h.l.WithOp(op)
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		http.Error(w, "empty key", http.StatusBadRequest)
+		return
+	}
+
+	if err := e.EmitSync(key, value); err != nil {
+		log.Error().Err(err).Str("key", key).Msg("failed to emit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info().Str("key", key).Interface("value", value).Send()
+}
+
+func (h *adminHandler) listBlocksHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "adminHandler.listBlocksHandler"
+	log := h.l.WithOp(op)
+
+	now := time.Now()
+	resp := blockListResponse{Blocks: []blockResponse{}}
+	err := h.iterate(h.blockView, func(key string, value any) {
+		if v, ok := value.(blocker.BlockValue); ok && v.Active(now) {
+			resp.Blocks = append(resp.Blocks, blockResponse{
+				Name:      key,
+				State:     string(v.StateAt(now)),
+				ExpiresAt: v.ExpiresAt,
+				Reason:    v.Reason,
+				Actor:     v.Actor,
+				BlockedAt: v.BlockedAt,
+			})
+		}
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate view")
+		h.writeViewError(w, err)
+		return
+	}
+	resp.Count = len(resp.Blocks)
+
+	if err := writeJSON(w, http.StatusOK, resp); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (h *adminHandler) listCensorHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "adminHandler.listCensorHandler"
+	log := h.l.WithOp(op)
+
+	resp := censorListResponse{Words: []censorResponse{}}
+	err := h.iterate(h.censorView, func(key string, value any) {
+		if change, ok := value.(string); ok {
+			resp.Words = append(resp.Words,
+				censorResponse{Word: key, Change: change})
+		}
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to iterate view")
+		h.writeViewError(w, err)
+		return
+	}
+	resp.Count = len(resp.Words)
+
+	if err := writeJSON(w, http.StatusOK, resp); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (h *adminHandler) iterate(
+	v tableView, fn func(key string, value any),
+) error {
+	if !v.Recovered() {
+		return errTableRecovering
+	}
+
+	it, err := v.Iterator()
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	for it.Next() {
+		value, err := it.Value()
+		if err != nil {
+			return err
+		}
+		fn(it.Key(), value)
+	}
+	return it.Err()
+}
+
+func (h *adminHandler) writeViewError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTableRecovering) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+func TestCensorHandlerRejectsInvalidRequests(t *testing.T) {
+	h := &adminHandler{l: logger.New("error")}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields map[string]string
+	}{
+		{
+			name:       "unknown field",
+			body:       `{"change":"***","x":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: map[string]string{"x": "unknown field"},
+		},
+		{
+			name:       "blank change",
+			body:       `{"change":"  "}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: map[string]string{"change": "required"},
+		},
+		{
+			name:       "invalid UTF-8",
+			body:       "{\"change\":\"\xff\"}",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "oversized body",
+			body: `{"change":"` +
+				strings.Repeat("*", maxAdminBodySize) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/admin/censor/apple",
+				strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.censorHandler(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var resp validationError
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("response is not a validation error: %v", err)
+			}
+			if !maps.Equal(resp.Fields, tt.wantFields) {
+				t.Fatalf("fields = %v, want %v", resp.Fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -4,17 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
 
+	"github.com/lovoo/goka"
+	"github.com/niksmo/messaging/internal/auth"
 	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/internal/processor/blocker"
+	"github.com/niksmo/messaging/internal/processor/censor"
+	"github.com/niksmo/messaging/internal/processor/collector"
+	"github.com/niksmo/messaging/internal/processor/filter"
+	"github.com/niksmo/messaging/internal/processor/inbox"
+	"github.com/niksmo/messaging/internal/processor/outbox"
+	"github.com/niksmo/messaging/internal/processor/userblock"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 type options struct {
-	addr     string
-	brokers  []string
-	outTopic string
-	inTopic  string
+	addr       string
+	brokers    []string
+	outTopic   string
+	inTopic    string
+	convTopic  string
+	authSecret []byte
+	rateLimit  float64
+	rateBurst  int
+	rateByIP   bool
+	lim        limits
 }
 
 type App struct {
@@ -22,6 +40,26 @@ type App struct {
 	s   *http.Server
 	e   *messaging.Emitter
 	v   *messaging.View
+	cv  *messaging.View
+	a   *auth.Signer
+	rl  *rateLimiter
+	lim limits
+	hc  *healthChecker
+	ws  sync.WaitGroup
+
+	inboxView  *goka.View
+	outboxView *goka.View
+	dedupView  *goka.View
+	cmdEmit    *goka.Emitter
+
+	userBlockEmit *goka.Emitter
+	userBlockView *goka.View
+
+	blockEmit  *goka.Emitter
+	blockView  *goka.View
+	auditView  *goka.View
+	censorEmit *goka.Emitter
+	censorView *goka.View
 }
 
 type Option func(*options) error
@@ -54,19 +92,118 @@ func WithInTopic(topic string) Option {
 	}
 }
 
+// WithConversationTopic sets the group of the processor that keeps
+// conversations keyed by pairs of users.
+func WithConversationTopic(topic string) Option {
+	return func(o *options) error {
+		o.convTopic = topic
+		return nil
+	}
+}
+
+func WithAuthSecret(secret string) Option {
+	return func(o *options) error {
+		o.authSecret = []byte(secret)
+		return nil
+	}
+}
+
+// WithRateLimit limits every sender to rps messages per second with bursts
+// of up to burst messages. Zero rps disables the limit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *options) error {
+		if rps < 0 {
+			return errors.New("rate limit is negative")
+		}
+		if rps > 0 && burst < 1 {
+			return errors.New("rate limit burst must be positive")
+		}
+		o.rateLimit = rps
+		o.rateBurst = burst
+		return nil
+	}
+}
+
+// WithRateLimitByIP additionally applies the rate limit per client IP.
+func WithRateLimitByIP() Option {
+	return func(o *options) error {
+		o.rateByIP = true
+		return nil
+	}
+}
+
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("max body size must be positive")
+		}
+		o.lim.maxBodySize = n
+		return nil
+	}
+}
+
+func WithMaxContentLength(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("max content length must be positive")
+		}
+		o.lim.maxContentLength = n
+		return nil
+	}
+}
+
+func WithMaxBatchBodySize(n int64) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("max batch body size must be positive")
+		}
+		o.lim.maxBatchBodySize = n
+		return nil
+	}
+}
+
+func WithMaxBatchItems(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New("max batch items must be positive")
+		}
+		o.lim.maxBatchItems = n
+		return nil
+	}
+}
+
 func New(l logger.Logger, opts ...Option) (*App, error) {
-	var options options
+	options := options{
+		lim: limits{
+			maxBodySize:      defaultMaxBodySize,
+			maxContentLength: defaultMaxContentLength,
+			maxBatchBodySize: defaultMaxBatchBodySize,
+			maxBatchItems:    defaultMaxBatchItems,
+		},
+	}
 	for _, opt := range opts {
 		if err := opt(&options); err != nil {
 			return nil, err
 		}
 	}
 
-	s := &http.Server{Addr: options.addr}
+	// Streaming handlers run until their request context is done, so the
+	// base context is canceled on shutdown to release them.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	s := &http.Server{
+		Addr:        options.addr,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	s.RegisterOnShutdown(cancelBase)
 
-	app := &App{log: l, s: s}
+	app := &App{log: l, s: s, lim: options.lim}
 
-	err := app.initMsgEmitter(options.brokers, options.outTopic)
+	err := app.initAuth(options.authSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initMsgEmitter(options.brokers, options.outTopic)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +213,45 @@ func New(l logger.Logger, opts ...Option) (*App, error) {
 		return nil, err
 	}
 
+	err = app.initConvView(options.brokers, options.convTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initCmdEmitter(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initUserBlocks(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initInboxView(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initOutboxView(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initDedupView(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.initModeration(options.brokers)
+	if err != nil {
+		return nil, err
+	}
+
+	app.hc = newHealthChecker(app.v)
+
+	app.initRateLimiter(options.rateLimit, options.rateBurst, options.rateByIP)
+
 	app.setupHandler()
 
 	return app, nil
@@ -102,10 +278,31 @@ func (a *App) Run(ctx context.Context) {
 		}
 	})
 
+	go metrics.ObserveState(ctx, "server_view", a.v.ObserveStateChanges())
+
 	go a.runView(ctx, func(err error) {
+		a.hc.viewFailed.Store(true)
 		log.Error().Err(err).Msg("failed to run view")
 		cancel()
 	})
+
+	go metrics.ObserveState(
+		ctx, "server_conversation_view", a.cv.ObserveStateChanges())
+
+	go a.runConvView(ctx, func(err error) {
+		log.Error().Err(err).Msg("failed to run conversation view")
+	})
+
+	tableViews := []*goka.View{
+		a.inboxView, a.outboxView, a.dedupView, a.userBlockView,
+		a.blockView, a.auditView, a.censorView,
+	}
+	for _, v := range tableViews {
+		go a.runTableView(ctx, v, func(err error) {
+			log.Error().Err(err).Str(
+				"table", v.Topic()).Msg("failed to run table view")
+		})
+	}
 }
 
 func (a *App) Close(timeoutCtx context.Context) {
@@ -116,9 +313,40 @@ func (a *App) Close(timeoutCtx context.Context) {
 		log.Error().Err(err).Msg("failed shutdown server gracefully")
 		return
 	}
+
+	// Shutdown does not wait for hijacked WebSocket connections. The
+	// canceled base context ends them, so only their handlers are awaited.
+	if err := waitGroup(timeoutCtx, &a.ws); err != nil {
+		log.Error().Err(err).Msg("failed to close websocket connections")
+		return
+	}
 	log.Info().Msg("server closed gracefully")
 }
 
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *App) initAuth(secret []byte) error {
+	signer, err := auth.NewSigner(secret)
+	if err != nil {
+		return fmt.Errorf("failed to construct token signer: %w", err)
+	}
+	a.a = signer
+	return nil
+}
+
 func (a *App) initMsgEmitter(brokers []string, topic string) error {
 	e, err := messaging.NewEmitter(a.log, brokers, topic)
 	if err != nil {
@@ -137,10 +365,119 @@ func (a *App) initMsgView(brokers []string, topic string) error {
 	return nil
 }
 
+func (a *App) initConvView(brokers []string, topic string) error {
+	v, err := messaging.NewView(a.log, brokers, topic)
+	if err != nil {
+		return fmt.Errorf("failed to construct conversation view: %w", err)
+	}
+	a.cv = v
+	return nil
+}
+
+func (a *App) initCmdEmitter(brokers []string) error {
+	e, err := goka.NewEmitter(
+		brokers, collector.CommandStream, collector.NewCommandCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct command emitter: %w", err)
+	}
+	a.cmdEmit = e
+	return nil
+}
+
+func (a *App) initUserBlocks(brokers []string) error {
+	var err error
+
+	a.userBlockEmit, err = goka.NewEmitter(
+		brokers, userblock.Stream, userblock.NewChangeCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct user block emitter: %w", err)
+	}
+	a.userBlockView, err = goka.NewView(
+		brokers, userblock.Table, userblock.NewBlockListCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct user block view: %w", err)
+	}
+	return nil
+}
+
+func (a *App) initInboxView(brokers []string) error {
+	v, err := goka.NewView(
+		brokers, inbox.Table, inbox.NewConversationsCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct inbox view: %w", err)
+	}
+	a.inboxView = v
+	return nil
+}
+
+func (a *App) initOutboxView(brokers []string) error {
+	v, err := goka.NewView(
+		brokers, outbox.Table, messaging.NewRejectionListCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct outbox view: %w", err)
+	}
+	a.outboxView = v
+	return nil
+}
+
+func (a *App) initDedupView(brokers []string) error {
+	v, err := goka.NewView(
+		brokers, filter.DedupTable, filter.NewSeenKeysCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct dedup view: %w", err)
+	}
+	a.dedupView = v
+	return nil
+}
+
+func (a *App) initModeration(brokers []string) error {
+	var err error
+
+	a.blockEmit, err = goka.NewEmitter(
+		brokers, blocker.Stream, blocker.NewEventCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct block emitter: %w", err)
+	}
+	a.blockView, err = goka.NewView(
+		brokers, blocker.Table, blocker.NewBlockValueCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct block view: %w", err)
+	}
+	a.auditView, err = goka.NewView(
+		brokers, blocker.AuditTable, blocker.NewHistoryCodec(a.log))
+	if err != nil {
+		return fmt.Errorf("failed to construct audit view: %w", err)
+	}
+
+	censorCodec := censor.NewCensorValueCodec(a.log)
+	a.censorEmit, err = goka.NewEmitter(brokers, censor.Stream, censorCodec)
+	if err != nil {
+		return fmt.Errorf("failed to construct censor emitter: %w", err)
+	}
+	a.censorView, err = goka.NewView(brokers, censor.Table, censorCodec)
+	if err != nil {
+		return fmt.Errorf("failed to construct censor view: %w", err)
+	}
+	return nil
+}
+
+func (a *App) initRateLimiter(rps float64, burst int, byIP bool) {
+	if rps == 0 {
+		return
+	}
+	a.rl = newRateLimiter(a.log, rps, burst, byIP)
+}
+
 func (a *App) setupHandler() {
 	mux := http.NewServeMux()
-	NewHandler(a.log, mux, a.e, a.v)
-	a.s.Handler = mux
+	mux.Handle("GET /metrics", metrics.Handler())
+	a.hc.register(mux)
+	NewHandler(a.log, mux, healthEmitter{a.e, a.hc}, backfilledView{a.v},
+		a.cv, a.inboxView, a.cmdEmit, a.userBlockEmit, a.userBlockView,
+		a.outboxView, a.dedupView, a.a, a.rl, a.lim, &a.ws)
+	NewAdminHandler(a.log, mux, a.a,
+		a.blockEmit, a.blockView, a.auditView, a.censorEmit, a.censorView)
+	a.s.Handler = instrument(mux)
 }
 
 func (a *App) runServer(ctx context.Context, errCb func(error)) {
@@ -156,3 +493,19 @@ func (a *App) runView(ctx context.Context, errCb func(error)) {
 		errCb(err)
 	}
 }
+
+func (a *App) runConvView(ctx context.Context, errCb func(error)) {
+	err := a.cv.Run(ctx)
+	if err != nil {
+		errCb(err)
+	}
+}
+
+func (a *App) runTableView(
+	ctx context.Context, v *goka.View, errCb func(error),
+) {
+	err := v.Run(ctx)
+	if err != nil {
+		errCb(err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/niksmo/messaging/internal/auth"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+var errForbidden = errors.New("token subject does not match user name")
+
+type subjectKey struct{}
+
+type authenticator interface {
+	Verify(token string) (auth.Claims, error)
+}
+
+// authorized requires a valid bearer token in the Authorization header
+// whose subject is the {name} path value.
+func (h *httpHandler) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return h.authorizedBy(bearerToken, next)
+}
+
+// streamAuthorized is authorized for event streams. Browser EventSource
+// and WebSocket clients cannot set headers, so the token is also accepted
+// as the access_token parameter. Other endpoints do not accept it to keep
+// tokens out of URLs that end up in logs and browser history.
+func (h *httpHandler) streamAuthorized(
+	next http.HandlerFunc,
+) http.HandlerFunc {
+	return h.authorizedBy(streamToken, next)
+}
+
+func (h *httpHandler) authorizedBy(
+	token func(r *http.Request) string, next http.HandlerFunc,
+) http.HandlerFunc {
+	const op = "httpHandler.authorized"
+	log := h.l.WithOp(op)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := verifyRequest(w, token(r), h.a, log)
+		if !ok {
+			return
+		}
+
+		name := h.getNamePath(r)
+		if claims.Subject != name {
+			log.Info().Str("subject", claims.Subject).Str(
+				"name", name).Msg("subject does not match name")
+			http.Error(w, errForbidden.Error(), http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), subjectKey{}, claims.Subject)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// subjectFrom returns the token subject verified by authorized.
+func subjectFrom(ctx context.Context) string {
+	subject, _ := ctx.Value(subjectKey{}).(string)
+	return subject
+}
+
+// verifyRequest writes a 401 response and reports false when the token
+// of the request is missing or invalid.
+func verifyRequest(
+	w http.ResponseWriter, token string, a authenticator, log logger.Logger,
+) (auth.Claims, bool) {
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return auth.Claims{}, false
+	}
+
+	claims, err := a.Verify(token)
+	if err != nil {
+		log.Info().Err(err).Msg("rejected token")
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.Claims{}, false
+	}
+	return claims, true
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func streamToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("access_token")
+}
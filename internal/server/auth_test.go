@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/niksmo/messaging/internal/auth"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+type stubAuthenticator map[string]auth.Claims
+
+func (a stubAuthenticator) Verify(token string) (auth.Claims, error) {
+	c, ok := a[token]
+	if !ok {
+		return auth.Claims{}, auth.ErrInvalidToken
+	}
+	return c, nil
+}
+
+func TestAccessTokenParameter(t *testing.T) {
+	log := logger.New("error")
+	a := stubAuthenticator{
+		"alice": {Subject: "alice"},
+		"root":  {Subject: "root", Admin: true},
+	}
+	h := &httpHandler{l: log, a: a}
+	ah := &adminHandler{l: log, a: a}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{name}", h.authorized(ok))
+	mux.HandleFunc("GET /{name}/events", h.streamAuthorized(ok))
+	mux.HandleFunc("GET /{name}/ws", h.streamAuthorized(ok))
+	mux.HandleFunc("GET /admin/blocks", ah.adminOnly(ok))
+
+	tests := []struct {
+		target string
+		header string
+		want   int
+	}{
+		{"/alice/events?access_token=alice", "", http.StatusOK},
+		{"/alice/ws?access_token=alice", "", http.StatusOK},
+		{"/alice/events", "alice", http.StatusOK},
+		{"/alice/events?access_token=bad", "", http.StatusUnauthorized},
+		{"/alice?access_token=alice", "", http.StatusUnauthorized},
+		{"/alice", "alice", http.StatusOK},
+		{"/admin/blocks?access_token=root", "", http.StatusUnauthorized},
+		{"/admin/blocks", "root", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.header)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
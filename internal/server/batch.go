@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+)
+
+type batchItem struct {
+	sendRequest
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type batchItemResult struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	ID     string            `json:"id,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type batchResponse struct {
+	Count   int               `json:"count"`
+	Sent    int               `json:"sent"`
+	Failed  int               `json:"failed"`
+	Results []batchItemResult `json:"results"`
+}
+
+// batchHandler validates every item on its own, emits the valid ones
+// asynchronously and answers 201 when all of them were sent or 207 with
+// per-item statuses otherwise. Every valid item costs a rate limit token
+// and the whole batch is refused with 429 when the tokens run short.
+func (h *httpHandler) batchHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.batchHandler"
+	log := h.l.WithOp(op)
+
+	senderName := h.getNamePath(r)
+
+	items, err := h.readBatch(w, r)
+	if err != nil {
+		log.Info().Err(err).Str("senderName", senderName).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+		return
+	}
+
+	results := make([]batchItemResult, len(items))
+	msgs := make([]messaging.Message, 0, len(items))
+	msgIdx := make([]int, 0, len(items))
+
+	for i, raw := range items {
+		results[i].Index = i
+
+		m, err := h.parseBatchItem(raw, senderName)
+		if err != nil {
+			results[i].setError(err)
+			continue
+		}
+
+		first, replayed, err := h.replay(senderName, m)
+		if err != nil {
+			results[i].setError(err)
+			continue
+		}
+		if replayed {
+			results[i].Status = http.StatusCreated
+			results[i].ID = first.ID
+			continue
+		}
+		msgs = append(msgs, stamp(senderName, m))
+		msgIdx = append(msgIdx, i)
+	}
+
+	if len(msgs) != 0 {
+		delay := h.rl.wait(r, subjectFrom(r.Context()), len(msgs))
+		if delay > 0 {
+			writeRateLimited(w, delay)
+			return
+		}
+	}
+
+	errs := h.e.EmitBatch(senderName, msgs)
+	for j, err := range errs {
+		i := msgIdx[j]
+		if err != nil {
+			metrics.EmitErrors.Inc()
+			log.Error().Err(err).Int("index", i).Msg("failed to emit")
+			results[i].Status = http.StatusInternalServerError
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = http.StatusCreated
+		results[i].ID = msgs[j].ID
+	}
+
+	resp := batchResponse{Count: len(results), Results: results}
+	for _, res := range results {
+		if res.Status == http.StatusCreated {
+			resp.Sent++
+		}
+	}
+	resp.Failed = resp.Count - resp.Sent
+
+	status := http.StatusCreated
+	if resp.Failed != 0 {
+		status = http.StatusMultiStatus
+	}
+	if err := writeJSON(w, status, resp); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+		return
+	}
+	log.Info().Str("senderName", senderName).Int("sent", resp.Sent).Int(
+		"failed", resp.Failed).Send()
+}
+
+func (h *httpHandler) readBatch(
+	w http.ResponseWriter, r *http.Request,
+) ([]json.RawMessage, error) {
+	data, err := readBody(w, r, h.lim.maxBatchBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []json.RawMessage
+	if err := decodeStrict(data, &items); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(items) == 0:
+		return nil, newValidationError("batch is empty")
+	case len(items) > h.lim.maxBatchItems:
+		return nil, newValidationError(fmt.Sprintf(
+			"batch exceeds %d items", h.lim.maxBatchItems))
+	}
+	return items, nil
+}
+
+func (h *httpHandler) parseBatchItem(
+	data []byte, senderName string,
+) (messaging.Message, error) {
+	var item batchItem
+	if err := decodeStrict(data, &item); err != nil {
+		return messaging.Message{}, err
+	}
+
+	key, err := parseIdempotencyKey(item.IdempotencyKey, "idempotency_key")
+	if err != nil {
+		return messaging.Message{}, err
+	}
+
+	err = h.lim.validateSendRequest(&item.sendRequest, senderName)
+	if err != nil {
+		return messaging.Message{}, err
+	}
+
+	return messaging.Message{
+		IdempotencyKey: key, To: item.To, Content: item.Content,
+	}, nil
+}
+
+func (res *batchItemResult) setError(err error) {
+	res.Status = http.StatusBadRequest
+	res.Error = err.Error()
+	var verr *validationError
+	if errors.As(err, &verr) {
+		res.Status = verr.status
+		res.Error = verr.Message
+		res.Fields = verr.Fields
+	}
+}
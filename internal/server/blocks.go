@@ -0,0 +1,108 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/internal/processor/userblock"
+)
+
+type userBlockResponse struct {
+	Peer      string    `json:"peer"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+type userBlockListResponse struct {
+	Count  int                 `json:"count"`
+	Blocks []userBlockResponse `json:"blocks"`
+}
+
+// listUserBlocksHandler lists the peers the user blocked, unlike the
+// admin block list of globally banned senders.
+func (h *httpHandler) listUserBlocksHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "httpHandler.listUserBlocksHandler"
+	log := h.l.WithOp(op)
+
+	name := h.getNamePath(r)
+
+	bl, err := h.getBlockList(name)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := userBlockListResponse{
+		Blocks: make([]userBlockResponse, 0, len(bl)),
+	}
+	for peer, at := range bl {
+		resp.Blocks = append(resp.Blocks,
+			userBlockResponse{Peer: peer, BlockedAt: at})
+	}
+	slices.SortFunc(resp.Blocks, func(a, b userBlockResponse) int {
+		return cmp.Compare(a.Peer, b.Peer)
+	})
+	resp.Count = len(resp.Blocks)
+
+	if err := writeJSON(w, http.StatusOK, resp); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+	}
+}
+
+func (h *httpHandler) userBlockHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "httpHandler.userBlockHandler"
+	h.changeUserBlock(w, r, op, true)
+}
+
+func (h *httpHandler) userUnblockHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "httpHandler.userUnblockHandler"
+	h.changeUserBlock(w, r, op, false)
+}
+
+func (h *httpHandler) changeUserBlock(
+	w http.ResponseWriter, r *http.Request, op string, blocked bool,
+) {
+	log := h.l.WithOp(op)
+
+	name, peer := h.getNamePath(r), r.PathValue("peer")
+	if name == peer {
+		log.Info().Str("name", name).Msg("block self")
+		http.Error(w, "peer must differ from name", http.StatusBadRequest)
+		return
+	}
+
+	change := userblock.Change{Peer: peer, Blocked: blocked}
+	if err := h.ube.EmitSync(name, change); err != nil {
+		metrics.EmitErrors.Inc()
+		log.Error().Err(err).Msg("failed to emit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Info().Str("name", name).Str("peer", peer).Bool(
+		"blocked", blocked).Send()
+}
+
+func (h *httpHandler) getBlockList(name string) (userblock.BlockList, error) {
+	v, err := h.ubv.Get(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	bl, ok := v.(userblock.BlockList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected block list type %T", v)
+	}
+	return bl, nil
+}
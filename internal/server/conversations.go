@@ -0,0 +1,113 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/niksmo/messaging/internal/processor/inbox"
+)
+
+type conversationResponse struct {
+	Peer        string          `json:"peer"`
+	LastMessage messageResponse `json:"last_message"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Unread      int             `json:"unread"`
+}
+
+type conversationListResponse struct {
+	Count         int                    `json:"count"`
+	Unread        int                    `json:"unread"`
+	Conversations []conversationResponse `json:"conversations"`
+}
+
+// conversationsHandler lists the user's conversations, the most recently
+// updated first. Conversations whose first message the inbox processor
+// has not seen yet are left out.
+func (h *httpHandler) conversationsHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "httpHandler.conversationsHandler"
+	log := h.l.WithOp(op)
+
+	name := h.getNamePath(r)
+
+	cs, err := h.getConversations(name)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]inbox.Conversation, 0, len(cs))
+	for _, c := range cs {
+		if !c.UpdatedAt.IsZero() {
+			list = append(list, c)
+		}
+	}
+	slices.SortFunc(list, func(a, b inbox.Conversation) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Peer, b.Peer)
+	})
+
+	if err := h.writeConversations(w, r, list); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+		return
+	}
+	log.Info().Str("name", name).Int("conversations", len(list)).Send()
+}
+
+func (h *httpHandler) getConversations(
+	name string,
+) (inbox.Conversations, error) {
+	v, err := h.iv.Get(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	cs, ok := v.(inbox.Conversations)
+	if !ok {
+		return nil, fmt.Errorf("unexpected conversations type %T", v)
+	}
+	return cs, nil
+}
+
+func (h *httpHandler) writeConversations(
+	w http.ResponseWriter, r *http.Request, list []inbox.Conversation,
+) error {
+	if acceptsJSON(r) {
+		resp := conversationListResponse{
+			Count:         len(list),
+			Conversations: make([]conversationResponse, 0, len(list)),
+		}
+		for _, c := range list {
+			resp.Unread += c.Unread
+			resp.Conversations = append(resp.Conversations,
+				conversationResponse{
+					Peer:        c.Peer,
+					LastMessage: newMessageResponse(c.LastMessage),
+					UpdatedAt:   c.UpdatedAt,
+					Unread:      c.Unread,
+				})
+		}
+		return writeJSON(w, http.StatusOK, resp)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintln(w, "Conversations:"); err != nil {
+		return err
+	}
+	for _, c := range list {
+		_, err := fmt.Fprintf(w, "%q unread: %d at: %s last: %q\n",
+			c.Peer, c.Unread, c.UpdatedAt.Format(time.RFC3339),
+			c.LastMessage.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
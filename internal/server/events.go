@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+const sseKeepAliveInterval = 15 * time.Second
+
+func (h *httpHandler) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.eventsHandler"
+	readerName := h.getNamePath(r)
+	log := h.l.WithOp(op).With().Str("readerName", readerName).Logger()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error().Msg("streaming is not supported")
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID, err := parseCursor(r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		log.Error().Err(err).Msg("invalid Last-Event-ID")
+		http.Error(w, "Last-Event-ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Subscribe before the first read so that an update landing between
+	// the read and the subscription is not lost.
+	sub := h.v.Subscribe(readerName)
+	defer sub.Close()
+
+	ml, err := h.getMessages(readerName)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("Last-Event-ID") == "" && len(ml) != 0 {
+		lastID = ml[len(ml)-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Info().Int64("lastEventID", lastID).Msg("stream opened")
+	defer func() {
+		log.Info().Int64("lastEventID", lastID).Msg("stream closed")
+	}()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		lastID, err = writeEvents(w, ml, lastID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write event")
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			ml = nil
+		case <-sub.C:
+			ml, err = h.getMessages(readerName)
+			if err != nil {
+				log.Error().Err(err).Msg("failed get data from view")
+				return
+			}
+		}
+	}
+}
+
+func writeEvents(
+	w io.Writer, ml []messaging.Message, lastID int64,
+) (int64, error) {
+	for _, m := range messagesAfter(ml, lastID) {
+		data, err := json.Marshal(newMessageResponse(m))
+		if err != nil {
+			return lastID, err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: message\ndata: %s\n\n", m.Seq, data)
+		if err != nil {
+			return lastID, err
+		}
+		lastID = m.Seq
+	}
+	return lastID, nil
+}
@@ -1,21 +1,48 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/internal/processor/filter"
 	"github.com/niksmo/messaging/pkg/logger"
 )
 
 type msgEmitter interface {
 	Emit(key string, msg messaging.Message) error
+	EmitBatch(key string, msgs []messaging.Message) []error
 }
 
 type msgView interface {
 	Get(key string) (any, error)
+	Subscribe(key string) *messaging.Subscription
+	Recovered() bool
+}
+
+// backfilledView gives inbox messages stored before Seq and ID existed
+// the positions and IDs the collector assigns them on its next write, so
+// that cursors and commands work for them too.
+type backfilledView struct {
+	msgView
+}
+
+func (v backfilledView) Get(key string) (any, error) {
+	ml, err := v.msgView.Get(key)
+	if mlt, ok := ml.([]messaging.Message); ok {
+		messaging.Backfill(key, mlt)
+	}
+	return ml, err
+}
+
+type tableGetter interface {
+	Get(key string) (any, error)
+	Recovered() bool
 }
 
 type mux interface {
@@ -24,47 +51,98 @@ type mux interface {
 }
 
 type httpHandler struct {
-	l logger.Logger
-	e msgEmitter
-	v msgView
+	l   logger.Logger
+	e   msgEmitter
+	v   msgView
+	cv  msgView
+	iv  tableGetter
+	ce  syncEmitter
+	ube syncEmitter
+	ubv tableGetter
+	ov  tableGetter
+	dv  tableGetter
+	a   authenticator
+	rl  *rateLimiter
+	lim limits
+	ws  *sync.WaitGroup
 }
 
-func NewHandler(l logger.Logger, mux mux, e msgEmitter, v msgView) {
-	h := &httpHandler{l, e, v}
-	mux.HandleFunc("POST /{name}", h.sendHandler)
-	mux.HandleFunc("GET /{name}", h.feedHandler)
+func NewHandler(
+	l logger.Logger,
+	mux mux,
+	e msgEmitter,
+	v msgView,
+	cv msgView,
+	iv tableGetter,
+	ce syncEmitter,
+	ube syncEmitter,
+	ubv tableGetter,
+	ov tableGetter,
+	dv tableGetter,
+	a authenticator,
+	rl *rateLimiter,
+	lim limits,
+	ws *sync.WaitGroup,
+) {
+	h := &httpHandler{l, e, v, cv, iv, ce, ube, ubv, ov, dv, a, rl, lim, ws}
+	mux.HandleFunc("POST /{name}", h.authorized(h.limited(h.sendHandler)))
+	mux.HandleFunc("POST /{name}/batch", h.authorized(h.batchHandler))
+	mux.HandleFunc("GET /{name}", h.authorized(h.recovered(h.feedHandler)))
+	mux.HandleFunc("GET /{name}/events",
+		h.streamAuthorized(h.recovered(h.eventsHandler)))
+	mux.HandleFunc("GET /{name}/ws",
+		h.streamAuthorized(h.recovered(h.wsHandler)))
+	mux.HandleFunc("POST /{name}/read",
+		h.authorized(h.recovered(h.readHandler)))
+	mux.HandleFunc("DELETE /{name}/messages/{id}",
+		h.authorized(h.recovered(h.deleteHandler)))
+	mux.HandleFunc("GET /{name}/blocks",
+		h.authorized(h.viewRecovered(h.ubv, h.listUserBlocksHandler)))
+	mux.HandleFunc("PUT /{name}/blocks/{peer}",
+		h.authorized(h.userBlockHandler))
+	mux.HandleFunc("DELETE /{name}/blocks/{peer}",
+		h.authorized(h.userUnblockHandler))
+	mux.HandleFunc("GET /{name}/outbox",
+		h.authorized(h.viewRecovered(h.ov, h.outboxHandler)))
+	mux.HandleFunc("GET /{name}/with/{peer}",
+		h.authorized(h.viewRecovered(h.cv, h.conversationHandler)))
+	mux.HandleFunc("GET /{name}/conversations",
+		h.authorized(h.viewRecovered(h.iv, h.conversationsHandler)))
 }
 
 func (h *httpHandler) sendHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "httpHandler.sendHandler"
 	log := h.l.WithOp(op)
 
-	data, err := io.ReadAll(r.Body)
+	senderName := h.getNamePath(r)
+
+	m, err := h.readMessage(w, r, senderName)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to read request body")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Info().Err(err).Str("senderName", senderName).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
 		return
 	}
 
-	var m messaging.Message
-	err = json.Unmarshal(data, &m)
+	first, replayed, err := h.replay(senderName, m)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to unmarshal request body")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Info().Err(err).Str("senderName", senderName).Msg("key conflict")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
 		return
 	}
 
-	senderName := h.getNamePath(r)
-	m.From = senderName
-	err = h.e.Emit(senderName, m)
-	if err != nil {
+	if replayed {
+		m = first
+	} else if m, err = h.send(senderName, m); err != nil {
 		log.Error().Err(err).Msg("failed to emit")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	_, err = fmt.Fprintf(w, "sent message: %q\nto: %q\n", m.Content, m.To)
+	err = h.writeSent(w, r, m)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to write response")
 		return
@@ -75,39 +153,261 @@ func (h *httpHandler) sendHandler(w http.ResponseWriter, r *http.Request) {
 
 func (h *httpHandler) feedHandler(w http.ResponseWriter, r *http.Request) {
 	const op = "httpHandler.feedHandler"
+	readerName := h.getNamePath(r)
+	h.serveFeed(w, r, op, h.v, readerName, nil)
+}
+
+// conversationHandler serves the messages exchanged between the user and
+// the peer in both directions, paginated the same way as the inbox.
+func (h *httpHandler) conversationHandler(
+	w http.ResponseWriter, r *http.Request,
+) {
+	const op = "httpHandler.conversationHandler"
 	log := h.l.WithOp(op)
 
-	readerName := h.getNamePath(r)
+	name, peer := h.getNamePath(r), r.PathValue("peer")
+	if name == peer {
+		log.Info().Str("name", name).Msg("conversation with self")
+		http.Error(w, "peer must differ from name", http.StatusBadRequest)
+		return
+	}
+
+	h.serveFeed(w, r, op, h.cv, messaging.ConversationKey(name, peer),
+		func(m messaging.Message) bool { return m.VisibleTo(name) })
+}
 
-	ml, err := h.v.Get(readerName)
+// serveFeed serves the message list of key, leaving out the messages
+// visible rejects unless it is nil.
+func (h *httpHandler) serveFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	op string,
+	v msgView,
+	key string,
+	visible func(messaging.Message) bool,
+) {
+	log := h.l.WithOp(op)
+
+	pq, err := parsePageQuery(r)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid page query")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list := func() ([]messaging.Message, error) {
+		ml, err := h.listMessages(v, key)
+		if visible != nil && ml != nil {
+			ml = slices.DeleteFunc(slices.Clone(ml),
+				func(m messaging.Message) bool { return !visible(m) })
+		}
+		return ml, err
+	}
+
+	var mlt []messaging.Message
+	if pq.wait > 0 {
+		mlt, err = h.waitMessages(r.Context(), v, key, list, pq.after, pq.wait)
+	} else {
+		mlt, err = list()
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("failed get data from view")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if ml == nil {
-		log.Info().Str("readerName", readerName).Msg("no content")
+	if len(mlt) == 0 {
+		log.Info().Str("key", key).Msg("no content")
 		w.WriteHeader(http.StatusNoContent)
 		fmt.Fprintln(w, "no messages for you")
 		return
 	}
 
-	mlt, ok := ml.([]messaging.Message)
-	if !ok {
-		log.Error().Type("msgListType", ml).Msg("unexpected type")
-		http.Error(w, "", http.StatusInternalServerError)
+	p := paginate(mlt, pq)
+	if pq.wait > 0 && len(p.messages) == 0 {
+		log.Info().Str("key", key).Msg("no new messages")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.writeFeed(w, r, p); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
 		return
 	}
+	log.Info().Int("msgListSize", len(mlt)).Int(
+		"pageSize", len(p.messages)).Str("key", key).Send()
+}
+
+// waitMessages blocks until the list has messages after since, the wait
+// expires or ctx is done, and returns the list as it is at that moment.
+// Updates of key that add nothing to the list do not end the wait.
+func (h *httpHandler) waitMessages(
+	ctx context.Context,
+	v msgView,
+	key string,
+	list func() ([]messaging.Message, error),
+	since int64,
+	wait time.Duration,
+) ([]messaging.Message, error) {
+	sub := v.Subscribe(key)
+	defer sub.Close()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		ml, err := list()
+		if err != nil || len(messagesAfter(ml, since)) != 0 {
+			return ml, err
+		}
+
+		select {
+		case <-sub.C:
+		case <-timer.C:
+			return ml, nil
+		case <-ctx.Done():
+			return ml, nil
+		}
+	}
+}
+
+func (h *httpHandler) send(
+	senderName string, m messaging.Message,
+) (messaging.Message, error) {
+	m = stamp(senderName, m)
+	if err := h.e.Emit(senderName, m); err != nil {
+		metrics.EmitErrors.Inc()
+		return m, err
+	}
+	return m, nil
+}
+
+// replay returns the message the filter first accepted with the
+// idempotency key of m when m repeats it, so that a retry gets the
+// original ID and creation time. Reusing the key for a different message is a conflict.
+// Until the dedup view has recovered retries are emitted again and left
+// to the filter to drop.
+func (h *httpHandler) replay(
+	senderName string, m messaging.Message,
+) (messaging.Message, bool, error) {
+	const op = "httpHandler.replay"
+	log := h.l.WithOp(op)
+
+	if m.IdempotencyKey == "" || !h.dv.Recovered() {
+		return m, false, nil
+	}
+
+	v, err := h.dv.Get(senderName)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		return m, false, nil
+	}
+	seen, _ := v.(filter.SeenKeys)
+	first, ok := seen[m.IdempotencyKey]
+	if !ok || !first.Active(time.Now()) {
+		return m, false, nil
+	}
+
+	if !first.Matches(m) {
+		return m, false, &validationError{
+			status:  http.StatusConflict,
+			Message: "idempotency key was used for a different message",
+		}
+	}
+	m.ID = first.ID
+	m.From = senderName
+	m.CreatedAt = first.CreatedAt
+	return m, true, nil
+}
+
+// stamp sets the sender, a unique ID and the creation time of an outgoing
+// message.
+func stamp(senderName string, m messaging.Message) messaging.Message {
+	m.ID = messaging.NewID()
+	m.From = senderName
+	m.CreatedAt = time.Now().UTC()
+	return m
+}
+
+func (h *httpHandler) writeSent(
+	w http.ResponseWriter, r *http.Request, m messaging.Message,
+) error {
+	if acceptsJSON(r) {
+		return writeJSON(w, http.StatusCreated, sendResponse{
+			Status:    "sent",
+			ID:        m.ID,
+			To:        m.To,
+			Content:   m.Content,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	_, err := fmt.Fprintf(w, "sent message: %q\nto: %q\n", m.Content, m.To)
+	if err != nil || m.ID == "" {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\n", m.ID)
+	return err
+}
+
+func (h *httpHandler) writeFeed(
+	w http.ResponseWriter, r *http.Request, p page,
+) error {
+	if p.nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", p.nextCursor)
+	}
+
+	if acceptsJSON(r) {
+		return writeJSON(w, http.StatusOK, newFeedResponse(p))
+	}
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Messages:")
-	for i, m := range mlt {
+	if _, err := fmt.Fprintln(w, "Messages:"); err != nil {
+		return err
+	}
+	for i, m := range p.messages {
 		n := i + 1
-		fmt.Fprintf(w, "%d from: %q content: %q\n", n, m.From, m.Content)
+		_, err := fmt.Fprintf(w, "%d from: %q content: %q\n", n, m.From, m.Content)
+		if err != nil {
+			return err
+		}
+	}
+	if p.nextCursor == "" {
+		return nil
+	}
+
+	// Without a cursor only the first page of the oldest messages is
+	// served, so tell plain-text clients how to get the rest.
+	param := "after"
+	if r.URL.Query().Has("before") {
+		param = "before"
+	}
+	_, err := fmt.Fprintf(w, "Shown %d of %d messages, next page: ?%s=%s\n",
+		len(p.messages), p.total, param, p.nextCursor)
+	return err
+}
+
+func (h *httpHandler) getMessages(name string) ([]messaging.Message, error) {
+	return h.listMessages(h.v, name)
+}
+
+func (h *httpHandler) listMessages(
+	v msgView, key string,
+) ([]messaging.Message, error) {
+	ml, err := v.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if ml == nil {
+		return nil, nil
+	}
+
+	mlt, ok := ml.([]messaging.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message list type %T", ml)
 	}
-	log.Info().Int(
-		"msgListSize", len(mlt)).Str("readerName", readerName).Send()
+	return mlt, nil
 }
 
 func (h *httpHandler) getNamePath(r *http.Request) string {
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/processor/filter"
+	"github.com/niksmo/messaging/pkg/logger"
+)
+
+type stubTable map[string]any
+
+func (t stubTable) Get(key string) (any, error) { return t[key], nil }
+
+func (t stubTable) Recovered() bool { return true }
+
+func TestReplay(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := messaging.Message{To: "jack", Content: "hi"}
+	keyed := func(key, content string) messaging.Message {
+		return messaging.Message{
+			IdempotencyKey: key, To: "jack", Content: content,
+		}
+	}
+	h := &httpHandler{
+		l: logger.New("error"),
+		dv: stubTable{"david": filter.SeenKeys{"k1": {
+			ExpiresAt: time.Now().Add(time.Hour),
+			ID:        "first-id",
+			CreatedAt: created,
+			Digest:    first.Digest(),
+		}}},
+	}
+
+	tests := []struct {
+		name         string
+		sender       string
+		m            messaging.Message
+		wantReplayed bool
+		wantStatus   int
+	}{
+		{
+			name:         "retry gets the stored id",
+			sender:       "david",
+			m:            keyed("k1", "hi"),
+			wantReplayed: true,
+		},
+		{
+			name:   "unknown key",
+			sender: "david",
+			m:      keyed("k2", "hi"),
+		},
+		{
+			name:   "same key of another sender",
+			sender: "sam",
+			m:      keyed("k1", "hi"),
+		},
+		{
+			name:   "no key",
+			sender: "david",
+			m:      keyed("", "hi"),
+		},
+		{
+			name:       "key reused for another message",
+			sender:     "david",
+			m:          keyed("k1", "bye"),
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replayed, err := h.replay(tt.sender, tt.m)
+
+			var verr *validationError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &verr) || verr.status != tt.wantStatus {
+					t.Fatalf("err = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %t, want %t", replayed, tt.wantReplayed)
+			}
+			if !replayed {
+				return
+			}
+			if got.ID != "first-id" || !got.CreatedAt.Equal(created) ||
+				got.From != tt.sender {
+				t.Fatalf("replayed message = %+v", got)
+			}
+		})
+	}
+}
+
+func TestStampAssignsUniqueIDs(t *testing.T) {
+	m := messaging.Message{IdempotencyKey: "k1", To: "jack", Content: "hi"}
+	a, b := stamp("david", m), stamp("david", m)
+	if a.ID == "" || a.ID == b.ID {
+		t.Fatalf("ids = %q, %q, want distinct", a.ID, b.ID)
+	}
+	if a.From != "david" || a.IdempotencyKey != "k1" {
+		t.Fatalf("stamped message = %+v", a)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+var (
+	errViewRecovering = errors.New("message view is recovering")
+	errViewFailed     = errors.New("message view failed")
+	errEmitterFailed  = errors.New("message emitter failed")
+)
+
+type recoverer interface {
+	Recovered() bool
+}
+
+const (
+	emitWindow        = 30 * time.Second
+	emitMinSamples    = 5
+	emitMaxErrorRatio = 0.5
+)
+
+// healthChecker reports the server ready once the message view has
+// recovered the collector table, while the view has not failed and while
+// most recent emits succeed.
+type healthChecker struct {
+	v          recoverer
+	viewFailed atomic.Bool
+	emits      emitStats
+}
+
+func newHealthChecker(v recoverer) *healthChecker {
+	return &healthChecker{v: v}
+}
+
+func (hc *healthChecker) check() error {
+	switch {
+	case hc.viewFailed.Load():
+		return errViewFailed
+	case !hc.v.Recovered():
+		return errViewRecovering
+	case hc.emits.failing(time.Now()):
+		return errEmitterFailed
+	}
+	return nil
+}
+
+func (hc *healthChecker) register(mux mux) {
+	mux.HandleFunc("GET /healthz", hc.livenessHandler)
+	mux.HandleFunc("GET /readyz", hc.readinessHandler)
+}
+
+func (hc *healthChecker) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+func (hc *healthChecker) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if err := hc.check(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+type healthEmitter struct {
+	msgEmitter
+	hc *healthChecker
+}
+
+func (e healthEmitter) Emit(key string, msg messaging.Message) error {
+	err := e.msgEmitter.Emit(key, msg)
+	failed := 0
+	if err != nil {
+		failed = 1
+	}
+	e.hc.emits.record(time.Now(), 1, failed)
+	return err
+}
+
+func (e healthEmitter) EmitBatch(
+	key string, msgs []messaging.Message,
+) []error {
+	errs := e.msgEmitter.EmitBatch(key, msgs)
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	e.hc.emits.record(time.Now(), len(errs), failed)
+	return errs
+}
+
+// emitStats counts emit outcomes in the current and the previous window.
+// Failures age out with the windows, so an unready server that gets no
+// traffic becomes ready again instead of waiting for a successful emit.
+type emitStats struct {
+	mu        sync.Mutex
+	start     time.Time
+	cur, prev emitCount
+}
+
+type emitCount struct {
+	total, failed int
+}
+
+func (s *emitStats) record(now time.Time, total, failed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate(now)
+	s.cur.total += total
+	s.cur.failed += failed
+}
+
+// failing reports whether enough emits were made over the last two
+// windows and too many of them failed.
+func (s *emitStats) failing(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotate(now)
+	total := s.cur.total + s.prev.total
+	failed := s.cur.failed + s.prev.failed
+	return total >= emitMinSamples &&
+		float64(failed) >= emitMaxErrorRatio*float64(total)
+}
+
+func (s *emitStats) rotate(now time.Time) {
+	switch elapsed := now.Sub(s.start); {
+	case elapsed < emitWindow:
+		return
+	case elapsed < 2*emitWindow:
+		s.prev = s.cur
+		s.start = s.start.Add(emitWindow)
+	default:
+		s.prev = emitCount{}
+		s.start = now
+	}
+	s.cur = emitCount{}
+}
+
+// recovered refuses reads until the view has recovered, otherwise users
+// that do have messages would get an empty inbox.
+func (h *httpHandler) recovered(next http.HandlerFunc) http.HandlerFunc {
+	return h.viewRecovered(h.v, next)
+}
+
+func (h *httpHandler) viewRecovered(
+	v recoverer, next http.HandlerFunc,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !v.Recovered() {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, errViewRecovering.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmitStatsFailing(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type record struct {
+		after         time.Duration
+		total, failed int
+	}
+
+	tests := []struct {
+		name    string
+		records []record
+		checkAt time.Duration
+		want    bool
+	}{
+		{
+			name:    "single transient failure",
+			records: []record{{0, 1, 1}},
+			want:    false,
+		},
+		{
+			name:    "most emits fail",
+			records: []record{{0, 3, 3}, {time.Second, 3, 1}},
+			checkAt: time.Second,
+			want:    true,
+		},
+		{
+			name:    "most emits succeed",
+			records: []record{{0, 10, 4}},
+			want:    false,
+		},
+		{
+			name:    "failures span two windows",
+			records: []record{{0, 3, 3}, {emitWindow, 3, 3}},
+			checkAt: emitWindow,
+			want:    true,
+		},
+		{
+			name:    "failures age out without traffic",
+			records: []record{{0, 10, 10}},
+			checkAt: 2 * emitWindow,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := emitStats{start: start}
+			for _, r := range tt.records {
+				s.record(start.Add(r.after), r.total, r.failed)
+			}
+			if got := s.failing(start.Add(tt.checkAt)); got != tt.want {
+				t.Fatalf("failing = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
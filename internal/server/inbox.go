@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/internal/processor/collector"
+)
+
+var errMessageNotFound = errors.New("message not found")
+
+type readRequest struct {
+	ID string `json:"id"`
+}
+
+// readHandler marks the inbox messages up to and including the given ID
+// as read. The collector applies the command asynchronously.
+func (h *httpHandler) readHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.readHandler"
+	log := h.l.WithOp(op)
+
+	name := h.getNamePath(r)
+
+	id, err := h.readMessageID(w, r)
+	if err != nil {
+		log.Info().Err(err).Str("name", name).Msg("invalid request")
+		if err := writeRequestError(w, err); err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+		return
+	}
+
+	h.command(w, op, name, collector.Command{
+		Action: collector.ActionRead, MessageID: id,
+	})
+}
+
+func (h *httpHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.deleteHandler"
+	h.command(w, op, h.getNamePath(r), collector.Command{
+		Action: collector.ActionDelete, MessageID: r.PathValue("id"),
+	})
+}
+
+func (h *httpHandler) readMessageID(
+	w http.ResponseWriter, r *http.Request,
+) (string, error) {
+	data, err := readBody(w, r, h.lim.maxBodySize)
+	if err != nil {
+		return "", err
+	}
+
+	var req readRequest
+	if err := decodeStrict(data, &req); err != nil {
+		return "", err
+	}
+
+	if req.ID == "" {
+		verr := newValidationError("invalid read request")
+		verr.addField("id", "required")
+		return "", verr
+	}
+	return req.ID, nil
+}
+
+// command answers 404 for IDs that are not in the inbox, so that clients
+// get feedback the asynchronous collector cannot give them.
+func (h *httpHandler) command(
+	w http.ResponseWriter, op, name string, cmd collector.Command,
+) {
+	log := h.l.WithOp(op)
+
+	ml, err := h.getMessages(name)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	found := slices.ContainsFunc(ml, func(m messaging.Message) bool {
+		return m.ID == cmd.MessageID
+	})
+	if !found {
+		log.Info().Str("name", name).Str("id", cmd.MessageID).Msg("not found")
+		http.Error(w, errMessageNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := h.ce.EmitSync(name, cmd); err != nil {
+		metrics.EmitErrors.Inc()
+		log.Error().Err(err).Msg("failed to emit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	log.Info().Str("name", name).Str("action", cmd.Action).Str(
+		"id", cmd.MessageID).Send()
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+type rejectionResponse struct {
+	ID         string    `json:"id,omitempty"`
+	To         string    `json:"to"`
+	Reason     string    `json:"reason"`
+	RejectedAt time.Time `json:"rejected_at"`
+}
+
+type outboxResponse struct {
+	Count    int                 `json:"count"`
+	Rejected []rejectionResponse `json:"rejected"`
+}
+
+// outboxHandler lists the latest messages of the user that the filter
+// rejected after sendHandler had accepted them, the newest first.
+func (h *httpHandler) outboxHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.outboxHandler"
+	log := h.l.WithOp(op)
+
+	name := h.getNamePath(r)
+
+	rl, err := h.getRejections(name)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.writeOutbox(w, r, rl); err != nil {
+		log.Error().Err(err).Msg("failed to write response")
+		return
+	}
+	log.Info().Str("name", name).Int("rejected", len(rl)).Send()
+}
+
+func (h *httpHandler) getRejections(
+	name string,
+) ([]messaging.Rejection, error) {
+	v, err := h.ov.Get(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	rl, ok := v.([]messaging.Rejection)
+	if !ok {
+		return nil, fmt.Errorf("unexpected rejection list type %T", v)
+	}
+	return rl, nil
+}
+
+func (h *httpHandler) writeOutbox(
+	w http.ResponseWriter, r *http.Request, rl []messaging.Rejection,
+) error {
+	if acceptsJSON(r) {
+		resp := outboxResponse{
+			Count:    len(rl),
+			Rejected: make([]rejectionResponse, 0, len(rl)),
+		}
+		for i := len(rl) - 1; i >= 0; i-- {
+			resp.Rejected = append(resp.Rejected, rejectionResponse{
+				ID:         rl[i].MessageID,
+				To:         rl[i].To,
+				Reason:     rl[i].Reason,
+				RejectedAt: rl[i].RejectedAt,
+			})
+		}
+		return writeJSON(w, http.StatusOK, resp)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintln(w, "Rejected:"); err != nil {
+		return err
+	}
+	for i := len(rl) - 1; i >= 0; i-- {
+		_, err := fmt.Fprintf(w, "%s to: %q reason: %s at: %s\n",
+			rl[i].MessageID, rl[i].To, rl[i].Reason,
+			rl[i].RejectedAt.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+	maxWait          = time.Minute
+)
+
+type pageQuery struct {
+	limit  int
+	after  int64
+	before int64
+	wait   time.Duration
+}
+
+type page struct {
+	messages   []messaging.Message
+	total      int
+	nextCursor string
+}
+
+func parsePageQuery(r *http.Request) (pageQuery, error) {
+	q := pageQuery{limit: defaultPageLimit}
+	values := r.URL.Query()
+
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return q, fmt.Errorf(
+				"limit must be an integer from 1 to %d", maxPageLimit)
+		}
+		q.limit = limit
+	}
+
+	var err error
+	if q.after, err = parseCursor(values.Get("after")); err != nil {
+		return q, fmt.Errorf("after: %w", err)
+	}
+	if q.before, err = parseCursor(values.Get("before")); err != nil {
+		return q, fmt.Errorf("before: %w", err)
+	}
+
+	if s := values.Get("since"); s != "" {
+		if values.Has("after") {
+			return q, errors.New("after and since are mutually exclusive")
+		}
+		if q.after, err = parseCursor(s); err != nil {
+			return q, fmt.Errorf("since: %w", err)
+		}
+	}
+
+	if q.after != 0 && q.before != 0 {
+		return q, errors.New("after and before are mutually exclusive")
+	}
+
+	if s := values.Get("wait"); s != "" {
+		wait, err := time.ParseDuration(s)
+		if err != nil || wait < 0 || wait > maxWait {
+			return q, fmt.Errorf(
+				"wait must be a duration from 0s to %s", maxWait)
+		}
+		if q.before != 0 {
+			return q, errors.New("wait and before are mutually exclusive")
+		}
+		q.wait = wait
+	}
+	return q, nil
+}
+
+func parseCursor(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	c, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || c < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+func formatCursor(seq int64) string {
+	return strconv.FormatInt(seq, 10)
+}
+
+// paginate expects ml to be ordered by Seq as the collector appends it.
+// The next cursor continues in the direction of the query: forward for
+// after (or no cursor) and backward for before.
+func paginate(ml []messaging.Message, q pageQuery) page {
+	p := page{total: len(ml)}
+
+	if q.before != 0 {
+		end := sort.Search(len(ml), func(i int) bool {
+			return ml[i].Seq >= q.before
+		})
+		start := max(0, end-q.limit)
+		p.messages = ml[start:end]
+		if start > 0 {
+			p.nextCursor = formatCursor(ml[start].Seq)
+		}
+		return p
+	}
+
+	start := 0
+	if q.after != 0 {
+		start = sort.Search(len(ml), func(i int) bool {
+			return ml[i].Seq > q.after
+		})
+	}
+	end := min(len(ml), start+q.limit)
+	p.messages = ml[start:end]
+	if end < len(ml) && end > start {
+		p.nextCursor = formatCursor(ml[end-1].Seq)
+	}
+	return p
+}
+
+func messagesAfter(ml []messaging.Message, seq int64) []messaging.Message {
+	start := sort.Search(len(ml), func(i int) bool {
+		return ml[i].Seq > seq
+	})
+	return ml[start:]
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+func TestParseCursor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"42", 42, false},
+		{"-1", 0, true},
+		{"abc", 0, true},
+		{"1.5", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseCursor(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("cursor = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	ml := make([]messaging.Message, 0, 5)
+	for _, seq := range []int64{3, 5, 7, 9, 11} {
+		ml = append(ml, messaging.Message{Seq: seq})
+	}
+
+	tests := []struct {
+		name     string
+		q        pageQuery
+		wantSeqs []int64
+		wantNext string
+	}{
+		{
+			name:     "first page",
+			q:        pageQuery{limit: 2},
+			wantSeqs: []int64{3, 5},
+			wantNext: "5",
+		},
+		{
+			name:     "after cursor",
+			q:        pageQuery{limit: 2, after: 5},
+			wantSeqs: []int64{7, 9},
+			wantNext: "9",
+		},
+		{
+			name:     "last page has no cursor",
+			q:        pageQuery{limit: 2, after: 9},
+			wantSeqs: []int64{11},
+		},
+		{
+			name:     "cursor between messages",
+			q:        pageQuery{limit: 10, after: 6},
+			wantSeqs: []int64{7, 9, 11},
+		},
+		{
+			name:     "after the newest",
+			q:        pageQuery{limit: 2, after: 11},
+			wantSeqs: []int64{},
+		},
+		{
+			name:     "before cursor",
+			q:        pageQuery{limit: 2, before: 9},
+			wantSeqs: []int64{5, 7},
+			wantNext: "5",
+		},
+		{
+			name:     "before reaches the oldest",
+			q:        pageQuery{limit: 2, before: 7},
+			wantSeqs: []int64{3, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := paginate(ml, tt.q)
+
+			seqs := make([]int64, 0, len(p.messages))
+			for _, m := range p.messages {
+				seqs = append(seqs, m.Seq)
+			}
+			if !slices.Equal(seqs, tt.wantSeqs) {
+				t.Errorf("seqs = %v, want %v", seqs, tt.wantSeqs)
+			}
+			if p.nextCursor != tt.wantNext {
+				t.Errorf("next cursor = %q, want %q", p.nextCursor, tt.wantNext)
+			}
+			if p.total != len(ml) {
+				t.Errorf("total = %d, want %d", p.total, len(ml))
+			}
+		})
+	}
+}
+
+func TestPaginateBackfilledLegacyMessages(t *testing.T) {
+	ml := []messaging.Message{
+		{Content: "a"}, {Content: "b"}, {Content: "c"}, {Seq: 10},
+	}
+	messaging.Backfill("jack", ml)
+
+	var seqs []int64
+	q := pageQuery{limit: 2}
+	for range len(ml) {
+		p := paginate(ml, q)
+		for _, m := range p.messages {
+			seqs = append(seqs, m.Seq)
+		}
+		if p.nextCursor == "" {
+			break
+		}
+		after, err := parseCursor(p.nextCursor)
+		if err != nil {
+			t.Fatalf("next cursor %q: %v", p.nextCursor, err)
+		}
+		q.after = after
+	}
+
+	if want := []int64{1, 2, 3, 10}; !slices.Equal(seqs, want) {
+		t.Fatalf("seqs = %v, want %v", seqs, want)
+	}
+}
+
+// A plain GET serves the oldest page and points at the next one, so that
+// clients that used to get the whole inbox notice the rest.
+func TestDefaultPage(t *testing.T) {
+	ml := make([]messaging.Message, 0, 120)
+	for seq := range int64(120) {
+		ml = append(ml, messaging.Message{Seq: seq + 1, From: "david"})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/jack", nil)
+	q, err := parsePageQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != (pageQuery{limit: defaultPageLimit}) {
+		t.Fatalf("query = %+v", q)
+	}
+
+	p := paginate(ml, q)
+	if len(p.messages) != defaultPageLimit || p.messages[0].Seq != 1 {
+		t.Fatalf("page has %d messages from seq %d, want %d from seq 1",
+			len(p.messages), p.messages[0].Seq, defaultPageLimit)
+	}
+	if p.nextCursor != "50" {
+		t.Fatalf("next cursor = %q, want %q", p.nextCursor, "50")
+	}
+
+	w := httptest.NewRecorder()
+	if err := (&httpHandler{}).writeFeed(w, r, p); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Header().Get("X-Next-Cursor"); got != "50" {
+		t.Errorf("X-Next-Cursor = %q, want %q", got, "50")
+	}
+	hint := "Shown 50 of 120 messages, next page: ?after=50\n"
+	if body := w.Body.String(); !strings.HasSuffix(body, hint) {
+		t.Errorf("body does not end with %q:\n%s", hint, body)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/niksmo/messaging/internal/metrics"
+	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+const (
+	limiterIdleTTL       = 10 * time.Minute
+	limiterSweepInterval = time.Minute
+)
+
+type bucket struct {
+	l        *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per sender name and, optionally, per
+// client IP. Buckets idle for longer than limiterIdleTTL are dropped.
+type rateLimiter struct {
+	log   logger.Logger
+	limit rate.Limit
+	burst int
+	byIP  bool
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(
+	l logger.Logger, rps float64, burst int, byIP bool,
+) *rateLimiter {
+	return &rateLimiter{
+		log:     l,
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		byIP:    byIP,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// wait charges n tokens to the subject and, when limiting by IP, to the
+// client address of r. It returns zero when the tokens were taken and
+// otherwise how long the caller has to wait, which is rate.InfDuration
+// when n exceeds the burst. A nil limiter never limits.
+func (rl *rateLimiter) wait(
+	r *http.Request, subject string, n int,
+) time.Duration {
+	const op = "rateLimiter.wait"
+
+	if rl == nil {
+		return 0
+	}
+
+	keys := []string{"name:" + subject}
+	if rl.byIP {
+		keys = append(keys, "ip:"+clientIP(r))
+	}
+
+	delay := rl.reserve(time.Now(), n, keys...)
+	if delay > 0 {
+		metrics.RateLimited.Inc()
+		log := rl.log.WithOp(op)
+		log.Info().Strs("keys", keys).Int("tokens", n).Dur(
+			"retryAfter", delay).Msg("rate limited")
+	}
+	return delay
+}
+
+// limited charges one token to the verified subject per request. It has
+// to run inside authorized, which puts the subject into the context.
+func (h *httpHandler) limited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if delay := h.rl.wait(r, subjectFrom(r.Context()), 1); delay > 0 {
+			writeRateLimited(w, delay)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeRateLimited(w http.ResponseWriter, delay time.Duration) {
+	if delay == rate.InfDuration {
+		http.Error(w, "request exceeds rate limit burst",
+			http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+}
+
+func retryAfterSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
+
+// reserve takes n tokens from every bucket or from none of them and
+// returns how long the caller has to wait when any bucket is short.
+func (rl *rateLimiter) reserve(
+	now time.Time, n int, keys ...string,
+) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(now)
+
+	var delay time.Duration
+	reservations := make([]*rate.Reservation, 0, len(keys))
+	for _, key := range keys {
+		r := rl.bucket(now, key).l.ReserveN(now, n)
+		reservations = append(reservations, r)
+		delay = max(delay, r.DelayFrom(now))
+	}
+
+	if delay > 0 {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	return delay
+}
+
+func (rl *rateLimiter) bucket(now time.Time, key string) *bucket {
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{l: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b
+}
+
+func (rl *rateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < limiterSweepInterval {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > limiterIdleTTL {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
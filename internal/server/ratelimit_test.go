@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/niksmo/messaging/pkg/logger"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type step struct {
+		after time.Duration
+		n     int
+		keys  []string
+		delay time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		rps   float64
+		burst int
+		steps []step
+	}{
+		{
+			name:  "burst then wait for refill",
+			rps:   1,
+			burst: 2,
+			steps: []step{
+				{0, 1, []string{"a"}, 0},
+				{0, 1, []string{"a"}, 0},
+				{0, 1, []string{"a"}, time.Second},
+				{time.Second, 1, []string{"a"}, 0},
+			},
+		},
+		{
+			name:  "buckets are independent",
+			rps:   1,
+			burst: 1,
+			steps: []step{
+				{0, 1, []string{"a"}, 0},
+				{0, 1, []string{"b"}, 0},
+				{0, 1, []string{"a"}, time.Second},
+			},
+		},
+		{
+			name:  "limited key charges no other bucket",
+			rps:   1,
+			burst: 1,
+			steps: []step{
+				{0, 1, []string{"a"}, 0},
+				{0, 1, []string{"b", "a"}, time.Second},
+				{0, 1, []string{"b"}, 0},
+			},
+		},
+		{
+			name:  "n tokens at once",
+			rps:   1,
+			burst: 5,
+			steps: []step{
+				{0, 3, []string{"a"}, 0},
+				{0, 3, []string{"a"}, time.Second},
+				{0, 2, []string{"a"}, 0},
+			},
+		},
+		{
+			name:  "n above burst never fits",
+			rps:   1,
+			burst: 5,
+			steps: []step{
+				{0, 6, []string{"a"}, rate.InfDuration},
+				{0, 5, []string{"a"}, 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := newRateLimiter(logger.New("error"), tt.rps, tt.burst, false)
+			at := now
+			for i, s := range tt.steps {
+				at = at.Add(s.after)
+				delay := rl.reserve(at, s.n, s.keys...)
+				if delay != s.delay {
+					t.Fatalf("step %d: delay = %s, want %s", i, delay, s.delay)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := newRateLimiter(logger.New("error"), 1, 1, false)
+
+	rl.reserve(now, 1, "idle")
+	rl.reserve(now.Add(limiterIdleTTL), 1, "busy")
+	rl.reserve(now.Add(limiterIdleTTL+limiterSweepInterval+time.Second), 1,
+		"busy")
+
+	if _, ok := rl.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := rl.buckets["busy"]; !ok {
+		t.Error("busy bucket was swept")
+	}
+}
+
+func TestNilRateLimiterNeverLimits(t *testing.T) {
+	var rl *rateLimiter
+	if delay := rl.wait(nil, "a", 1000); delay != 0 {
+		t.Fatalf("delay = %s, want 0", delay)
+	}
+}
+
+func TestLimitedChargesVerifiedSubject(t *testing.T) {
+	log := logger.New("error")
+	h := &httpHandler{
+		l:  log,
+		a:  stubAuthenticator{"alice": {Subject: "alice"}},
+		rl: newRateLimiter(log, 0.001, 1, false),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{name}", h.authorized(h.limited(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})))
+
+	send := func(token string) int {
+		r := httptest.NewRequest(http.MethodPost, "/alice", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for range 3 {
+		if code := send(""); code != http.StatusUnauthorized {
+			t.Fatalf("anonymous send: status = %d, want %d",
+				code, http.StatusUnauthorized)
+		}
+	}
+	if code := send("alice"); code != http.StatusCreated {
+		t.Fatalf("first send: status = %d, want %d", code, http.StatusCreated)
+	}
+	if code := send("alice"); code != http.StatusTooManyRequests {
+		t.Fatalf("second send: status = %d, want %d",
+			code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+const contentTypeJSON = "application/json"
+
+type messageResponse struct {
+	ID          string    `json:"id,omitempty"`
+	Seq         int64     `json:"seq"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+	DeliveredAt time.Time `json:"delivered_at,omitzero"`
+	ReadAt      time.Time `json:"read_at,omitzero"`
+}
+
+type feedResponse struct {
+	Count      int               `json:"count"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	Messages   []messageResponse `json:"messages"`
+}
+
+type sendResponse struct {
+	Status    string    `json:"status"`
+	ID        string    `json:"id,omitempty"`
+	To        string    `json:"to"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+func newMessageResponse(m messaging.Message) messageResponse {
+	return messageResponse{
+		ID:          m.ID,
+		Seq:         m.Seq,
+		From:        m.From,
+		To:          m.To,
+		Content:     m.Content,
+		CreatedAt:   m.CreatedAt,
+		DeliveredAt: m.DeliveredAt,
+		ReadAt:      m.ReadAt,
+	}
+}
+
+func newFeedResponse(p page) feedResponse {
+	messages := make([]messageResponse, 0, len(p.messages))
+	for _, m := range p.messages {
+		messages = append(messages, newMessageResponse(m))
+	}
+	return feedResponse{
+		Count:      len(messages),
+		Total:      p.total,
+		NextCursor: p.nextCursor,
+		Messages:   messages,
+	}
+}
+
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && mediaType == contentTypeJSON {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
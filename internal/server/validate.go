@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/niksmo/messaging/internal/messaging"
+)
+
+const (
+	defaultMaxBodySize      = 64 << 10
+	defaultMaxContentLength = 4096
+	defaultMaxBatchBodySize = 8 << 20
+	defaultMaxBatchItems    = 1000
+	maxIdempotencyKeyLength = 255
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+type limits struct {
+	maxBodySize      int64
+	maxContentLength int
+	maxBatchBodySize int64
+	maxBatchItems    int
+}
+
+type sendRequest struct {
+	To      string `json:"to"`
+	Content string `json:"content"`
+}
+
+type validationError struct {
+	status  int
+	Message string            `json:"error"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func (e *validationError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	fields := make([]string, 0, len(e.Fields))
+	for _, field := range slices.Sorted(maps.Keys(e.Fields)) {
+		fields = append(fields, field+": "+e.Fields[field])
+	}
+	return e.Message + ": " + strings.Join(fields, ", ")
+}
+
+func newValidationError(msg string) *validationError {
+	return &validationError{status: http.StatusBadRequest, Message: msg}
+}
+
+func (e *validationError) addField(field, msg string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	e.Fields[field] = msg
+}
+
+func readBody(
+	w http.ResponseWriter, r *http.Request, limit int64,
+) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &validationError{
+				status: http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf(
+					"request body exceeds %d bytes", maxBytesErr.Limit),
+			}
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (lim limits) parseSendRequest(
+	data []byte, senderName string,
+) (sendRequest, error) {
+	var req sendRequest
+	if err := decodeStrict(data, &req); err != nil {
+		return req, err
+	}
+	return req, lim.validateSendRequest(&req, senderName)
+}
+
+// decodeStrict checks the raw bytes for valid UTF-8 before decoding
+// because encoding/json silently replaces invalid sequences.
+func decodeStrict(data []byte, v any) error {
+	if !utf8.Valid(data) {
+		return newValidationError("request body is not valid UTF-8")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		verr := newValidationError("malformed request body")
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			verr.addField(strings.Trim(field, `"`), "unknown field")
+		}
+		return verr
+	}
+	if dec.More() {
+		return newValidationError("request body must be a single JSON value")
+	}
+	return nil
+}
+
+func (lim limits) validateSendRequest(req *sendRequest, senderName string) error {
+	req.To = strings.TrimSpace(req.To)
+
+	verr := newValidationError("invalid message")
+	switch {
+	case req.To == "":
+		verr.addField("to", "required")
+	case req.To == senderName:
+		verr.addField("to", "must differ from the sender")
+	}
+
+	switch n := utf8.RuneCountInString(req.Content); {
+	case strings.TrimSpace(req.Content) == "":
+		verr.addField("content", "required")
+	case n > lim.maxContentLength:
+		verr.addField("content", fmt.Sprintf(
+			"must be at most %d characters, got %d", lim.maxContentLength, n))
+	}
+
+	if len(verr.Fields) != 0 {
+		return verr
+	}
+	return nil
+}
+
+func (h *httpHandler) readMessage(
+	w http.ResponseWriter, r *http.Request, senderName string,
+) (messaging.Message, error) {
+	key, err := parseIdempotencyKey(
+		r.Header.Get(idempotencyKeyHeader), idempotencyKeyHeader)
+	if err != nil {
+		return messaging.Message{}, err
+	}
+
+	data, err := readBody(w, r, h.lim.maxBodySize)
+	if err != nil {
+		return messaging.Message{}, err
+	}
+
+	req, err := h.lim.parseSendRequest(data, senderName)
+	if err != nil {
+		return messaging.Message{}, err
+	}
+
+	return messaging.Message{
+		IdempotencyKey: key, To: req.To, Content: req.Content,
+	}, nil
+}
+
+func parseIdempotencyKey(key, field string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	verr := newValidationError("invalid idempotency key")
+	if len(key) > maxIdempotencyKeyLength {
+		verr.addField(field, fmt.Sprintf(
+			"must be at most %d characters", maxIdempotencyKeyLength))
+		return "", verr
+	}
+	for _, c := range []byte(key) {
+		if c < '!' || c > '~' {
+			verr.addField(field, "must contain only visible ASCII characters")
+			return "", verr
+		}
+	}
+	return key, nil
+}
+
+func writeRequestError(w http.ResponseWriter, err error) error {
+	var verr *validationError
+	if errors.As(err, &verr) {
+		return writeJSON(w, verr.status, verr)
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+	return nil
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// checkValidationError fails the test unless err is a validation error
+// with the status and the field errors given.
+func checkValidationError(
+	t *testing.T, err error, status int, fields map[string]string,
+) {
+	t.Helper()
+
+	if status == 0 {
+		if err != nil {
+			t.Fatalf("err = %v, want nil", err)
+		}
+		return
+	}
+
+	var verr *validationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a validation error", err)
+	}
+	if verr.status != status {
+		t.Errorf("status = %d, want %d", verr.status, status)
+	}
+	if !maps.Equal(verr.Fields, fields) {
+		t.Errorf("fields = %v, want %v", verr.Fields, fields)
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"within the limit", "12345678", 0},
+		{"over the limit", "123456789", http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(
+				http.MethodPost, "/", strings.NewReader(tt.body))
+			data, err := readBody(httptest.NewRecorder(), r, 8)
+			checkValidationError(t, err, tt.wantStatus, nil)
+			if err == nil && string(data) != tt.body {
+				t.Fatalf("data = %q, want %q", data, tt.body)
+			}
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantStatus int
+		wantFields map[string]string
+	}{
+		{
+			name: "valid",
+			data: `{"to":"jack","content":"hi"}`,
+		},
+		{
+			name:       "invalid UTF-8",
+			data:       "{\"to\":\"jack\",\"content\":\"\xff\"}",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown field",
+			data:       `{"to":"jack","content":"hi","x":1}`,
+			wantStatus: http.StatusBadRequest,
+			wantFields: map[string]string{"x": "unknown field"},
+		},
+		{
+			name:       "malformed",
+			data:       `{"to":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong type",
+			data:       `{"to":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "trailing value",
+			data:       `{"to":"jack"} {}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req sendRequest
+			err := decodeStrict([]byte(tt.data), &req)
+			checkValidationError(t, err, tt.wantStatus, tt.wantFields)
+		})
+	}
+}
+
+// TestDecodeStrictUnknownFieldMessage pins the encoding/json error text
+// that decodeStrict parses to report the unknown field name.
+func TestDecodeStrictUnknownFieldMessage(t *testing.T) {
+	var req sendRequest
+	err := decodeStrict([]byte(`{"name with spaces":1}`), &req)
+	checkValidationError(t, err, http.StatusBadRequest,
+		map[string]string{"name with spaces": "unknown field"})
+}
+
+func TestValidateSendRequest(t *testing.T) {
+	lim := limits{maxContentLength: 5}
+
+	tests := []struct {
+		name       string
+		req        sendRequest
+		wantTo     string
+		wantFields map[string]string
+	}{
+		{
+			name:   "valid",
+			req:    sendRequest{To: " jack ", Content: "hi"},
+			wantTo: "jack",
+		},
+		{
+			name:   "content at the limit in runes",
+			req:    sendRequest{To: "jack", Content: "привет"[:10]},
+			wantTo: "jack",
+		},
+		{
+			name: "empty",
+			req:  sendRequest{To: " ", Content: " \n"},
+			wantFields: map[string]string{
+				"to": "required", "content": "required",
+			},
+		},
+		{
+			name: "self-send",
+			req:  sendRequest{To: "david", Content: "hi"},
+			wantFields: map[string]string{
+				"to": "must differ from the sender",
+			},
+		},
+		{
+			name: "content too long",
+			req:  sendRequest{To: "jack", Content: "hello!"},
+			wantFields: map[string]string{
+				"content": "must be at most 5 characters, got 6",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := lim.validateSendRequest(&req, "david")
+			if tt.wantFields == nil {
+				checkValidationError(t, err, 0, nil)
+				if req.To != tt.wantTo {
+					t.Fatalf("to = %q, want %q", req.To, tt.wantTo)
+				}
+				return
+			}
+			checkValidationError(
+				t, err, http.StatusBadRequest, tt.wantFields)
+		})
+	}
+}
+
+func TestParseIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"visible ASCII", "order-42/retry~1", false},
+		{"at the limit", strings.Repeat("k", maxIdempotencyKeyLength), false},
+		{"too long", strings.Repeat("k", maxIdempotencyKeyLength+1), true},
+		{"space", "order 42", true},
+		{"control character", "order\t42", true},
+		{"non-ASCII", "заказ", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseIdempotencyKey(tt.key, "key")
+			if !tt.wantErr {
+				checkValidationError(t, err, 0, nil)
+				if key != tt.key {
+					t.Fatalf("key = %q, want %q", key, tt.key)
+				}
+				return
+			}
+			var verr *validationError
+			if !errors.As(err, &verr) || verr.Fields["key"] == "" {
+				t.Fatalf("err = %v, want a key field error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/niksmo/messaging/internal/messaging"
+	"github.com/rs/zerolog"
+)
+
+const (
+	wsWriteWait     = 10 * time.Second
+	wsPongWait      = 60 * time.Second
+	wsPingPeriod    = wsPongWait * 9 / 10
+	wsSendQueueSize = 64
+)
+
+const (
+	wsFrameMessage = "message"
+	wsFrameSent    = "sent"
+	wsFrameError   = "error"
+)
+
+var errWsSendQueueFull = errors.New("send queue is full")
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type wsOutFrame struct {
+	Type       string            `json:"type"`
+	Message    *messageResponse  `json:"message,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	RetryAfter int               `json:"retry_after,omitempty"`
+}
+
+type wsConn struct {
+	conn   *websocket.Conn
+	send   chan wsOutFrame
+	cancel context.CancelFunc
+	log    zerolog.Logger
+}
+
+func (h *httpHandler) wsHandler(w http.ResponseWriter, r *http.Request) {
+	const op = "httpHandler.wsHandler"
+	userName := h.getNamePath(r)
+	log := h.l.WithOp(op).With().Str("userName", userName).Logger()
+
+	// Counted before the upgrade hijacks the connection, while the server
+	// still tracks it, so that App.Close cannot miss the handler.
+	h.ws.Add(1)
+	defer h.ws.Done()
+
+	sub := h.v.Subscribe(userName)
+	defer sub.Close()
+
+	ml, err := h.getMessages(userName)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get data from view")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var lastSeq int64
+	if len(ml) != 0 {
+		lastSeq = ml[len(ml)-1].Seq
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to upgrade connection")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &wsConn{
+		conn:   conn,
+		send:   make(chan wsOutFrame, wsSendQueueSize),
+		cancel: cancel,
+		log:    log,
+	}
+
+	log.Info().Msg("connection opened")
+	subject := subjectFrom(r.Context())
+	handle := func(data []byte) {
+		if delay := h.rl.wait(r, subject, 1); delay > 0 {
+			c.enqueue(wsOutFrame{
+				Type:       wsFrameError,
+				Error:      "rate limit exceeded",
+				RetryAfter: retryAfterSeconds(delay),
+			})
+			return
+		}
+
+		req, err := h.lim.parseSendRequest(data, userName)
+		if err != nil {
+			var verr *validationError
+			if errors.As(err, &verr) {
+				c.enqueue(wsOutFrame{
+					Type: wsFrameError, Error: verr.Message, Fields: verr.Fields,
+				})
+				return
+			}
+			c.enqueue(wsOutFrame{Type: wsFrameError, Error: err.Error()})
+			return
+		}
+
+		m, err := h.send(userName, messaging.Message{
+			To: req.To, Content: req.Content,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed to emit")
+			c.enqueue(wsOutFrame{Type: wsFrameError, Error: err.Error()})
+			return
+		}
+		mr := newMessageResponse(m)
+		c.enqueue(wsOutFrame{Type: wsFrameSent, Message: &mr})
+	}
+	h.ws.Add(1)
+	go func() {
+		defer h.ws.Done()
+		c.readPump(h.lim.maxBodySize, handle)
+	}()
+
+	err = c.writePump(ctx, sub, func() ([]messaging.Message, error) {
+		return h.getMessages(userName)
+	}, lastSeq)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Error().Err(err).Msg("connection closed with error")
+		return
+	}
+	log.Info().Msg("connection closed")
+}
+
+func (c *wsConn) enqueue(f wsOutFrame) {
+	select {
+	case c.send <- f:
+	default:
+		c.log.Error().Err(errWsSendQueueFull).Msg("drop slow connection")
+		c.cancel()
+	}
+}
+
+func (c *wsConn) readPump(maxFrameSize int64, handle func([]byte)) {
+	defer c.cancel()
+
+	c.conn.SetReadLimit(maxFrameSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err,
+				websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.log.Error().Err(err).Msg("failed to read frame")
+			}
+			return
+		}
+		handle(data)
+	}
+}
+
+func (c *wsConn) writePump(
+	ctx context.Context,
+	sub *messaging.Subscription,
+	getMessages func() ([]messaging.Message, error),
+	lastSeq int64,
+) error {
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		c.closeConn()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case f := <-c.send:
+			if err := c.write(f); err != nil {
+				return err
+			}
+		case <-sub.C:
+			ml, err := getMessages()
+			if err != nil {
+				return err
+			}
+			for _, m := range messagesAfter(ml, lastSeq) {
+				mr := newMessageResponse(m)
+				if err := c.write(wsOutFrame{
+					Type: wsFrameMessage, Message: &mr,
+				}); err != nil {
+					return err
+				}
+				lastSeq = m.Seq
+			}
+		case <-ping.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *wsConn) write(f wsOutFrame) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(f)
+}
+
+func (c *wsConn) closeConn() {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	_ = c.conn.WriteControl(
+		websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+	c.conn.Close()
+}